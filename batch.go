@@ -0,0 +1,197 @@
+// Copyright 2011 Jose Luis Vázquez González josvazg@gmail.com
+// Use of this source code is governed by a BSD-style
+
+package remotize
+
+import (
+	"bytes"
+	"fmt"
+	"gob"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// BatchCall is a single call packed within a batch.
+//
+// Users DON'T need to care about this, as it is used by the autogenerated code.
+type BatchCall struct {
+	Method string
+	Args   []byte
+}
+
+// BatchArgs are the calls of a batch, in order.
+//
+// Users DON'T need to care about this, as it is used by the autogenerated code.
+type BatchArgs struct {
+	Calls []BatchCall
+}
+
+// BatchCallReply is the reply, or failure, of a single call within a batch.
+//
+// Users DON'T need to care about this, as it is used by the autogenerated code.
+type BatchCallReply struct {
+	Reply []byte
+	Error string
+}
+
+// BatchReply are the replies of a batch, in the same order as its calls.
+//
+// Users DON'T need to care about this, as it is used by the autogenerated code.
+type BatchReply struct {
+	Replies []BatchCallReply
+}
+
+// BatchResult is the outcome of a single call within a batch.
+type BatchResult struct {
+	// Results are the method results, in order, as the method returns them
+	Results []interface{}
+	// Error is the call failure or, if there was none, the method's own
+	// os.Error result (if any)
+	Error os.Error
+}
+
+// BatchDone completes a queued call once its reply arrived, writing back
+// in/out pointers and returning the method results and own error.
+type BatchDone func() ([]interface{}, os.Error)
+
+// queuedCall is a call waiting for its batch to be sent.
+type queuedCall struct {
+	method string
+	args   interface{}
+	reply  interface{}
+	done   BatchDone
+}
+
+// Batch packs many calls to a service in a single round-trip. The
+// autogenerated XXXBatch builders wrap it with the typed methods to queue
+// calls with.
+type Batch struct {
+	opts    *Options
+	cli     Caller
+	service string
+	queued  []queuedCall
+}
+
+// NewBatch returns an empty batch of calls to service through cli.
+//
+// Users DON'T need to care about this, as it is called by the autogenerated code.
+func (o *Options) NewBatch(cli Caller, service string) *Batch {
+	return &Batch{opts: o, cli: cli, service: service}
+}
+
+// Queue adds a call to method to the batch.
+//
+// Users DON'T need to care about this, as it is called by the autogenerated code.
+func (b *Batch) Queue(method string, args interface{}, reply interface{}, done BatchDone) {
+	b.queued = append(b.queued, queuedCall{method, args, reply, done})
+}
+
+// Len returns how many calls are waiting to be sent.
+func (b *Batch) Len() int {
+	return len(b.queued)
+}
+
+// Send sends all queued calls in a single round-trip and returns their
+// results, in order. The returned error is only set when the batch as a whole
+// failed. Once sent, the batch is empty and can be reused.
+//
+// The round-trip passes through the client interceptor chains as a call to
+// the service's RemotizeBatch method, with the BatchArgs holding the calls.
+// It may take as long as the policy timeouts of its calls together, failing
+// with ErrTimeout otherwise, and it is never retried.
+func (b *Batch) Send() ([]BatchResult, os.Error) {
+	queued := b.queued
+	b.queued = nil
+	var args BatchArgs
+	for _, q := range queued {
+		var buf bytes.Buffer
+		if e := gob.NewEncoder(&buf).Encode(q.args); e != nil {
+			return nil, e
+		}
+		args.Calls = append(args.Calls, BatchCall{q.method, buf.Bytes()})
+	}
+	var reply BatchReply
+	serviceMethod := b.service + ".RemotizeBatch"
+	p := Policy{Timeout: b.timeout(queued)}
+	e := b.opts.intercept(serviceMethod, &args, &reply, func() os.Error {
+		return p.call(b.cli, serviceMethod, &args, &reply)
+	})
+	if e != nil {
+		return nil, e
+	}
+	if len(reply.Replies) != len(queued) {
+		return nil, os.NewError(fmt.Sprintf("remotize: sent %d calls but got %d replies",
+			len(queued), len(reply.Replies)))
+	}
+	results := make([]BatchResult, len(queued))
+	for i, q := range queued {
+		r := reply.Replies[i]
+		if r.Error != "" {
+			results[i].Error = remoteError(r.Error)
+		} else if e := gob.NewDecoder(bytes.NewBuffer(r.Reply)).Decode(q.reply); e != nil {
+			results[i].Error = e
+		} else {
+			results[i].Results, results[i].Error = q.done()
+		}
+	}
+	return results, nil
+}
+
+// timeout returns how long the queued calls may take together, as their
+// policies say, or 0 (forever) if any of them may take forever.
+func (b *Batch) timeout(queued []queuedCall) int64 {
+	var timeout int64
+	for _, q := range queued {
+		p := b.opts.policy(q.method)
+		if p.Timeout <= 0 {
+			return 0
+		}
+		timeout += p.Timeout
+	}
+	return timeout
+}
+
+// ServeBatch runs all calls of a batch on service, in order, and replies
+// their outcomes.
+//
+// Users DON'T need to care about this, as it is called by the autogenerated code.
+func ServeBatch(service interface{}, args *BatchArgs, reply *BatchReply) os.Error {
+	v := reflect.ValueOf(service)
+	methods := make(map[string]int)
+	for i := 0; i < v.NumMethod(); i++ {
+		m := v.Type().Method(i)
+		if isRPCMethod(m) && m.Type.In(1).Kind() == reflect.Ptr &&
+			!strings.HasPrefix(m.Name, "Remotize") {
+			methods[m.Name] = i
+		}
+	}
+	reply.Replies = make([]BatchCallReply, len(args.Calls))
+	for i, c := range args.Calls {
+		r := &reply.Replies[i]
+		n, ok := methods[c.Method]
+		if !ok {
+			r.Error = "rpc: can't find method " + c.Method
+			continue
+		}
+		m := v.Method(n)
+		argv := reflect.New(m.Type().In(0).Elem())
+		if e := gob.NewDecoder(bytes.NewBuffer(c.Args)).DecodeValue(argv); e != nil {
+			r.Error = e.String()
+			continue
+		}
+		replyv := reflect.New(m.Type().In(1).Elem())
+		out := m.Call([]reflect.Value{argv, replyv})
+		if e, _ := out[0].Interface().(os.Error); e != nil {
+			r.Error = e.String()
+			continue
+		}
+		var buf bytes.Buffer
+		if e := gob.NewEncoder(&buf).EncodeValue(replyv); e != nil {
+			r.Error = e.String()
+			continue
+		}
+		r.Reply = buf.Bytes()
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2011 Jose Luis Vázquez González josvazg@gmail.com
+// Use of this source code is governed by a BSD-style
+
+package remotize
+
+import (
+	"os"
+	"rpc"
+)
+
+// OnCallbackError handles failures calling back a client from a func or
+// interface stub that has no error result to report them. By default
+// failures are just logged.
+var OnCallbackError ErrorHandler = LogOnError
+
+// callbackFailed is the ErrorHandler for callback proxies.
+func callbackFailed(method string, e os.Error) {
+	OnCallbackError(method, e)
+}
+
+// ServeCallback serves rcvr's methods to be called back from the server side
+// through a new stream on the given service. It returns the stream id the
+// server needs to reach the callback.
+//
+// Users DON'T need to care about this, as it is called by the autogenerated code.
+func ServeCallback(cli Caller, service string, rcvr interface{}) (uint64, os.Error) {
+	if rcvr == nil {
+		return 0, os.NewError("remotize: there is no service to serve the callback with")
+	}
+	srv := rpc.NewServer()
+	if e := srv.Register(rcvr); e != nil {
+		return 0, e
+	}
+	s, e := OpenStream(cli, service)
+	if e != nil {
+		return 0, e
+	}
+	go srv.ServeConn(s)
+	return s.Id, nil
+}
+
+// DialCallback returns an rpc client to call back through stream id.
+//
+// Users DON'T need to care about this, as it is called by the autogenerated code.
+func DialCallback(id uint64) (*rpc.Client, os.Error) {
+	s, e := FindStream(id)
+	if e != nil {
+		return nil, e
+	}
+	return rpc.NewClient(s), nil
+}
+
+// RemoteCallback returns a proxy of interface type iface calling back
+// through stream id.
+//
+// Users DON'T need to care about this, as it is called by the autogenerated code.
+func RemoteCallback(id uint64, iface interface{}) (interface{}, os.Error) {
+	cli, e := DialCallback(id)
+	if e != nil {
+		return nil, e
+	}
+	r, e := newRemote(cli, iface, WithErrorHandler(callbackFailed))
+	if e != nil {
+		cli.Close()
+		return nil, e
+	}
+	return r, nil
+}
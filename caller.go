@@ -0,0 +1,146 @@
+// Copyright 2011 Jose Luis Vázquez González josvazg@gmail.com
+// Use of this source code is governed by a BSD-style
+
+package remotize
+
+import (
+	"io"
+	"os"
+	"reflect"
+	"rpc"
+	"sync"
+	"unicode"
+	"utf8"
+)
+
+// Caller makes the calls of a remote proxy. A *rpc.Client is a Caller, but
+// any other transport can be plugged in behind a proxy without regenerating
+// it, like the ones returned by NewDirectCaller or NewHTTPCaller.
+type Caller interface {
+	// Call invokes serviceMethod, waits for it to complete and returns its
+	// error status, just as rpc.Client.Call
+	Call(serviceMethod string, args interface{}, reply interface{}) os.Error
+	// Go invokes serviceMethod asynchronously, signaling on done when it
+	// completes, just as rpc.Client.Go
+	Go(serviceMethod string, args interface{}, reply interface{}, done chan *rpc.Call) *rpc.Call
+}
+
+// Disconnect closes c, if it can be closed at all.
+//
+// Users DON'T need to care about this, as it is called by the autogenerated code.
+func Disconnect(c Caller) os.Error {
+	if closer, ok := c.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// goCall runs call asynchronously on behalf of a Caller's Go method.
+func goCall(call *rpc.Call, done chan *rpc.Call, do func() os.Error) *rpc.Call {
+	if done == nil {
+		done = make(chan *rpc.Call, 1)
+	}
+	call.Done = done
+	go func() {
+		call.Error = do()
+		call.Done <- call
+	}()
+	return call
+}
+
+// directCaller calls service methods in process, no encoding involved.
+type directCaller struct {
+	lock    sync.RWMutex
+	methods map[string]reflect.Value
+}
+
+// NewDirectCaller returns a Caller calling the rpc methods of the given
+// services right away within this same process, as if they were registered
+// on a rpc.Server. Args and replies are NOT copied, the service gets the
+// caller's own values.
+//
+// Services are usually the service wrappers returned by NewService.
+func NewDirectCaller(services ...interface{}) (Caller, os.Error) {
+	d := &directCaller{methods: make(map[string]reflect.Value)}
+	for _, service := range services {
+		if e := d.register(service); e != nil {
+			return nil, e
+		}
+	}
+	return d, nil
+}
+
+// register adds all rpc methods of service.
+func (d *directCaller) register(service interface{}) os.Error {
+	if service == nil {
+		return os.NewError("remotize: can't call a nil service directly")
+	}
+	v := reflect.ValueOf(service)
+	name := reflect.Indirect(v).Type().Name()
+	if name == "" {
+		return os.NewError("remotize: can't call directly an unnamed service " +
+			v.Type().String())
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for i := 0; i < v.NumMethod(); i++ {
+		m := v.Type().Method(i)
+		if isRPCMethod(m) {
+			d.methods[name+"."+m.Name] = v.Method(i)
+		}
+	}
+	return nil
+}
+
+// isRPCMethod tells whether m is suitable for rpc, that is, an exported
+// method like: func (t *T) MethodName(argType *T1, replyType *T2) os.Error
+func isRPCMethod(m reflect.Method) bool {
+	rune, _ := utf8.DecodeRuneInString(m.Name)
+	if !unicode.IsUpper(rune) {
+		return false
+	}
+	mt := m.Type
+	if mt.NumIn() != 3 || mt.NumOut() != 1 || mt.In(2).Kind() != reflect.Ptr {
+		return false
+	}
+	return mt.Out(0) == reflect.TypeOf((*os.Error)(nil)).Elem()
+}
+
+func (d *directCaller) Call(serviceMethod string, args interface{}, reply interface{}) os.Error {
+	d.lock.RLock()
+	m, ok := d.methods[serviceMethod]
+	d.lock.RUnlock()
+	if !ok {
+		return rpc.ServerError("rpc: can't find method " + serviceMethod)
+	}
+	if args == nil || reply == nil {
+		return os.NewError("remotize: nil args or reply for " + serviceMethod)
+	}
+	mt := m.Type()
+	argv := reflect.ValueOf(args)
+	if argv.Type() != mt.In(0) { // non pointer args can be passed as well
+		if mt.In(0).Kind() != reflect.Ptr || argv.Type() != mt.In(0).Elem() {
+			return os.NewError("remotize: wrong args type for " + serviceMethod)
+		}
+		p := reflect.New(argv.Type())
+		p.Elem().Set(argv)
+		argv = p
+	}
+	replyv := reflect.ValueOf(reply)
+	if replyv.Type() != mt.In(1) {
+		return os.NewError("remotize: wrong reply type for " + serviceMethod)
+	}
+	out := m.Call([]reflect.Value{argv, replyv})
+	if e, _ := out[0].Interface().(os.Error); e != nil {
+		return rpc.ServerError(e.String())
+	}
+	return nil
+}
+
+func (d *directCaller) Go(serviceMethod string, args interface{}, reply interface{},
+done chan *rpc.Call) *rpc.Call {
+	call := &rpc.Call{ServiceMethod: serviceMethod, Args: args, Reply: reply}
+	return goCall(call, done, func() os.Error {
+		return d.Call(serviceMethod, args, reply)
+	})
+}
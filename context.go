@@ -0,0 +1,246 @@
+// Copyright 2011 Jose Luis Vázquez González josvazg@gmail.com
+// Use of this source code is governed by a BSD-style
+
+package remotize
+
+import (
+	"os"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ErrCanceled is the error of a Context that was cancelled.
+var ErrCanceled = os.NewError("remotize: context canceled")
+
+// ErrDeadline is the error of a Context whose deadline passed.
+var ErrDeadline = os.NewError("remotize: context deadline exceeded")
+
+// Context carries a deadline and a cancellation signal. Remotized methods taking a
+// Context as their first argument don't send it, its deadline drives the client call
+// instead and the implementation gets a Context of its own expiring by that deadline,
+// and cancelled when the client cancels the call.
+type Context interface {
+	// Deadline returns when the work must be done, in nanoseconds since the epoch,
+	// or false if there is no deadline
+	Deadline() (int64, bool)
+	// Done returns a channel closed when the Context is cancelled or expires, or nil
+	// if it never is
+	Done() <-chan bool
+	// Err returns why Done was closed, or nil if it wasn't yet
+	Err() os.Error
+}
+
+// CancelFunc cancels a Context and releases its resources.
+type CancelFunc func()
+
+// background is the Context never cancelled
+type background struct{}
+
+func (background) Deadline() (int64, bool) { return 0, false }
+
+func (background) Done() <-chan bool { return nil }
+
+func (background) Err() os.Error { return nil }
+
+// Background returns an empty Context, never cancelled and without deadline.
+func Background() Context {
+	return background{}
+}
+
+// cancelContext is a Context cancelled by its CancelFunc, by its deadline or by its parent
+type cancelContext struct {
+	parent   Context
+	deadline int64
+	done     chan bool
+	lock     sync.Mutex
+	err      os.Error
+}
+
+func (c *cancelContext) Deadline() (int64, bool) {
+	return c.deadline, c.deadline > 0
+}
+
+func (c *cancelContext) Done() <-chan bool {
+	return c.done
+}
+
+func (c *cancelContext) Err() os.Error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.err
+}
+
+// cancel closes Done with e as the reason, unless it was already closed
+func (c *cancelContext) cancel(e os.Error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.err == nil {
+		c.err = e
+		close(c.done)
+	}
+}
+
+// watch cancels c when its parent is done or its deadline passes
+func (c *cancelContext) watch() {
+	var expired <-chan int64
+	if c.deadline > 0 {
+		expired = time.After(c.deadline - time.Nanoseconds())
+	}
+	select {
+	case <-c.parent.Done():
+		c.cancel(c.parent.Err())
+	case <-expired:
+		c.cancel(ErrDeadline)
+	case <-c.done:
+	}
+}
+
+// WithCancel returns a Context derived from parent that is cancelled by the CancelFunc
+// returned, which must be called once the work is done.
+func WithCancel(parent Context) (Context, CancelFunc) {
+	return WithDeadline(parent, 0)
+}
+
+// WithTimeout returns a Context derived from parent that expires within timeout
+// nanoseconds, or when cancelled by the CancelFunc returned.
+func WithTimeout(parent Context, timeout int64) (Context, CancelFunc) {
+	return WithDeadline(parent, time.Nanoseconds()+timeout)
+}
+
+// WithDeadline returns a Context derived from parent that expires by deadline, in
+// nanoseconds since the epoch (0 for none), or when cancelled by the CancelFunc returned.
+// The parent deadline still holds if it is earlier.
+func WithDeadline(parent Context, deadline int64) (Context, CancelFunc) {
+	if d, ok := parent.Deadline(); ok && (deadline <= 0 || d < deadline) {
+		deadline = d
+	}
+	c := &cancelContext{parent: parent, deadline: deadline, done: make(chan bool)}
+	if e := parent.Err(); e != nil {
+		c.cancel(e)
+		return c, func() {}
+	}
+	go c.watch()
+	return c, func() {
+		c.cancel(ErrCanceled)
+	}
+}
+
+// Remaining returns how many nanoseconds are left until the deadline of ctx, or 0 if
+// it has none. A deadline already passed leaves a nanosecond, so that it still expires.
+//
+// Users DON'T need to care about this, as it is called by the autogenerated code.
+func Remaining(ctx Context) int64 {
+	d, ok := ctx.Deadline()
+	if !ok {
+		return 0
+	}
+	if left := d - time.Nanoseconds(); left > 0 {
+		return left
+	}
+	return 1
+}
+
+// CancelData identifies a call taking a Context whose client gave up on it.
+//
+// Users DON'T need to care about this, as it is used by the autogenerated code.
+type CancelData struct {
+	Id uint64
+}
+
+// Cancel funcs of the calls being served, by call id
+var serving = make(map[uint64]CancelFunc)
+
+// Served calls table's lock
+var servingLock sync.Mutex
+
+// NewCallId returns a random id for a call taking a Context, so that clients can't
+// guess the ids of the calls of others.
+//
+// Users DON'T need to care about this, as it is called by the autogenerated code.
+func NewCallId() uint64 {
+	return randomId()
+}
+
+// ServerContext returns the Context handed to an implementation, expiring within the
+// remaining nanoseconds sent by the client (never if 0) or cancelled by the client
+// through call id (if not 0). The CancelFunc must be called once the implementation
+// returns.
+//
+// Users DON'T need to care about this, as it is called by the autogenerated code.
+func ServerContext(remaining int64, id uint64) (Context, CancelFunc) {
+	var ctx Context
+	var cancel CancelFunc
+	if remaining > 0 {
+		ctx, cancel = WithTimeout(Background(), remaining)
+	} else {
+		ctx, cancel = WithCancel(Background())
+	}
+	if id == 0 {
+		return ctx, cancel
+	}
+	servingLock.Lock()
+	serving[id] = cancel
+	servingLock.Unlock()
+	return ctx, func() {
+		servingLock.Lock()
+		serving[id] = nil, false
+		servingLock.Unlock()
+		cancel()
+	}
+}
+
+// ServeCancel cancels the Context of the call being served with the given id. A cancel
+// arriving before the call itself is ignored, the call deadline still holds then.
+//
+// Users DON'T need to care about this, as it is called by the autogenerated code.
+func ServeCancel(args, reply *CancelData) os.Error {
+	servingLock.Lock()
+	cancel := serving[args.Id]
+	servingLock.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	reply.Id = args.Id
+	return nil
+}
+
+// CallContext calls serviceMethod through c as Call does, but gives up as soon as ctx
+// is cancelled or expires, returning its error. Then the server is told to cancel the
+// Context of the implementation, through the service's RemotizeCancel method, unless
+// the call id is 0.
+//
+// Users DON'T need to care about this, as it is called by the autogenerated code.
+func (o *Options) CallContext(ctx Context, id uint64, c Caller, serviceMethod string,
+args interface{}, reply interface{}) os.Error {
+	if e := ctx.Err(); e != nil {
+		return e
+	}
+	if ctx.Done() == nil {
+		return o.Call(c, serviceMethod, args, reply)
+	}
+	// a reply arriving after giving up must not be written over the caller's one
+	attempt := reflect.New(reflect.TypeOf(reply).Elem())
+	plain := *o
+	plain.RePanic = false // panics are re-raised here, not within the calling goroutine
+	done := make(chan os.Error, 1)
+	go func() {
+		done <- plain.Call(c, serviceMethod, args, attempt.Interface())
+	}()
+	select {
+	case e := <-done:
+		if p := AsPanic(e); p != nil && o.RePanic {
+			panic(p)
+		}
+		if e == nil {
+			reflect.ValueOf(reply).Elem().Set(attempt.Elem())
+		}
+		return e
+	case <-ctx.Done():
+	}
+	if id != 0 {
+		service, _ := splitServiceMethod(serviceMethod)
+		go c.Call(service+".RemotizeCancel", &CancelData{id}, new(CancelData))
+	}
+	return ctx.Err()
+}
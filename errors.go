@@ -0,0 +1,223 @@
+// Copyright 2011 Jose Luis Vázquez González josvazg@gmail.com
+// Use of this source code is governed by a BSD-style
+
+package remotize
+
+import (
+	"gob"
+	"io"
+	"os"
+	"reflect"
+	"sync"
+)
+
+func init() {
+	gob.RegisterName("remotize.RemoteError", &RemoteError{})
+	markRegistered(reflect.TypeOf(&RemoteError{}))
+	for name, e := range map[string]os.Error{
+		"os.EOF":                 os.EOF,
+		"os.ENOENT":              os.ENOENT,
+		"os.EEXIST":              os.EEXIST,
+		"os.EPERM":               os.EPERM,
+		"os.EINVAL":              os.EINVAL,
+		"io.ErrUnexpectedEOF":    io.ErrUnexpectedEOF,
+		"remotize.ErrStreamGone": ErrStreamGone,
+		"remotize.ErrTimeout":    ErrTimeout,
+		"remotize.ErrCanceled":   ErrCanceled,
+		"remotize.ErrDeadline":   ErrDeadline,
+	} {
+		RegisterError(name, e)
+	}
+}
+
+// RemoteError is how any os.Error travels to and from remotized methods, so
+// that errors of any type survive the trip.
+//
+// Errors registered with RegisterError come back as the registered value
+// itself, so they can be compared as usual. The rest come back as a
+// *RemoteError.
+type RemoteError struct {
+	// Message is the original error message
+	Message string
+	// Type is the original error type, like "*os.PathError"
+	Type string
+	// Sentinel is the name the original error was registered with, if any
+	Sentinel string
+	// Cause is the error wrapped by the original one, if any
+	Cause *RemoteError
+}
+
+// String returns the original error message.
+func (r *RemoteError) String() string {
+	return r.Message
+}
+
+// Registered sentinel errors by name
+var sentinels = make(map[string]os.Error)
+
+// Sentinel error registry's lock
+var sentinelsLock sync.RWMutex
+
+// RegisterError registers a sentinel error e by name, to be recognized when
+// it travels, so that it comes back as e itself. Both ends must register it
+// by the same name.
+func RegisterError(name string, e os.Error) {
+	sentinelsLock.Lock()
+	defer sentinelsLock.Unlock()
+	sentinels[name] = e
+}
+
+// sentinelName returns the name e was registered with, if any.
+func sentinelName(e os.Error) string {
+	sentinelsLock.RLock()
+	defer sentinelsLock.RUnlock()
+	for name, s := range sentinels {
+		if same(e, s) {
+			return name
+		}
+	}
+	return ""
+}
+
+// same tells whether a and b are the same error value, without comparing
+// values of types that can't be compared.
+func same(a, b os.Error) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	ta, tb := reflect.TypeOf(a), reflect.TypeOf(b)
+	if ta != tb {
+		return false
+	}
+	switch ta.Kind() {
+	case reflect.Ptr, reflect.String, reflect.Bool, reflect.Int, reflect.Int8,
+		reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8,
+		reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a == b
+	}
+	return false
+}
+
+// errorType is the os.Error interface type
+var errorType = reflect.TypeOf((*os.Error)(nil)).Elem()
+
+// cause returns the error wrapped by e, that is, its first os.Error field.
+func cause(e os.Error) os.Error {
+	if r, ok := e.(*RemoteError); ok {
+		if r.Cause == nil {
+			return nil
+		}
+		return r.Cause.local()
+	}
+	v := reflect.Indirect(reflect.ValueOf(e))
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		if !f.CanInterface() || !f.Type().Implements(errorType) {
+			continue
+		}
+		if f.Kind() == reflect.Interface && f.IsNil() {
+			continue
+		}
+		if c, ok := f.Interface().(os.Error); ok && c != nil {
+			return c
+		}
+	}
+	return nil
+}
+
+// newRemoteError turns e, and the errors it wraps, into RemoteErrors.
+func newRemoteError(e os.Error) *RemoteError {
+	if e == nil {
+		return nil
+	}
+	if r, ok := e.(*RemoteError); ok {
+		return r
+	}
+	return &RemoteError{Message: e.String(), Type: reflect.TypeOf(e).String(),
+		Sentinel: sentinelName(e), Cause: newRemoteError(cause(e))}
+}
+
+// local returns the registered sentinel r stands for, or r itself.
+func (r *RemoteError) local() os.Error {
+	if r.Sentinel != "" {
+		sentinelsLock.RLock()
+		defer sentinelsLock.RUnlock()
+		if e, ok := sentinels[r.Sentinel]; ok {
+			return e
+		}
+	}
+	return r
+}
+
+// ToRemoteError returns e ready to travel, as a *RemoteError (or nil).
+//
+// Users DON'T need to care about this, as it is called by the autogenerated code.
+func ToRemoteError(e os.Error) os.Error {
+	if e == nil {
+		return nil
+	}
+	return newRemoteError(e)
+}
+
+// FromRemoteError returns the error that travelled as e, which is the
+// registered sentinel itself if e was one.
+//
+// Users DON'T need to care about this, as it is called by the autogenerated code.
+func FromRemoteError(e os.Error) os.Error {
+	if r, ok := e.(*RemoteError); ok && r != nil {
+		return r.local()
+	}
+	return e
+}
+
+// Is tells whether e is target or wraps it, even if e travelled as a
+// RemoteError. Targets are usually sentinel errors registered with
+// RegisterError.
+func Is(e, target os.Error) bool {
+	name := sentinelName(target)
+	for ; e != nil; e = cause(e) {
+		if same(e, target) {
+			return true
+		}
+		if r, ok := e.(*RemoteError); ok && name != "" && r.Sentinel == name {
+			return true
+		}
+	}
+	return false
+}
+
+// As tells whether e, or an error it wraps, can be assigned to the variable target points
+// to, and assigns the first such error to it. Errors that travelled are found as the
+// registered sentinel they stand for, if any, or as a *RemoteError otherwise.
+func As(e os.Error, target interface{}) bool {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		panic("remotize: As needs a non nil pointer as target")
+	}
+	t := v.Type().Elem()
+	for ; e != nil; e = cause(e) {
+		if r, ok := e.(*RemoteError); ok {
+			e = r.local()
+		}
+		if et := reflect.TypeOf(e); et == t || (t.Kind() == reflect.Interface && et.Implements(t)) {
+			v.Elem().Set(reflect.ValueOf(e))
+			return true
+		}
+	}
+	return false
+}
+
+// TypeOf returns the type name of the original error behind e, like
+// "*os.PathError", even if e travelled as a RemoteError.
+func TypeOf(e os.Error) string {
+	if e == nil {
+		return ""
+	}
+	if r, ok := e.(*RemoteError); ok {
+		return r.Type
+	}
+	return reflect.TypeOf(e).String()
+}
@@ -0,0 +1,57 @@
+// Copyright 2011 Jose Luis Vázquez González josvazg@gmail.com
+// Use of this source code is governed by a BSD-style
+
+package remotize
+
+import (
+	"os"
+)
+
+// Future is a remote call in flight, as started by the autogenerated
+// XXXAsync methods, whose typed futures wrap it to return the real results.
+//
+// A Future is meant to be waited for from a single goroutine.
+type Future struct {
+	done     chan os.Error
+	err      os.Error
+	complete bool
+}
+
+// Go starts calling serviceMethod through c as its policy says, without
+// waiting for it. Each call attempt passes through the client interceptor chains.
+//
+// Users DON'T need to care about this, as it is called by the autogenerated code.
+func (o *Options) Go(c Caller, serviceMethod string, args interface{}, reply interface{}) *Future {
+	plain := *o
+	plain.RePanic = false // panics are returned by Wait
+	f := &Future{done: make(chan os.Error, 1)}
+	go func() {
+		f.done <- plain.Call(c, serviceMethod, args, reply)
+	}()
+	return f
+}
+
+// Done reports whether the call completed, without blocking.
+func (f *Future) Done() bool {
+	if !f.complete {
+		select {
+		case f.err = <-f.done:
+			f.complete = true
+		default:
+		}
+	}
+	return f.complete
+}
+
+// Wait waits for the call to complete and returns its failure, if any. Calls
+// taking longer than their policy allows fail with ErrTimeout.
+func (f *Future) Wait() os.Error {
+	if !f.complete {
+		f.err = <-f.done
+		f.complete = true
+	}
+	if p := AsPanic(f.err); p != nil {
+		return p
+	}
+	return f.err
+}
@@ -0,0 +1,175 @@
+// Copyright 2011 Jose Luis Vázquez González josvazg@gmail.com
+// Use of this source code is governed by a BSD-style
+
+package remotize
+
+import (
+	"fmt"
+	"gob"
+	"os"
+	"reflect"
+	"sync"
+)
+
+// Types registered to travel within interfaces
+var registered = make(map[reflect.Type]bool)
+
+// Whether values of a type may hold interfaces, for the types checked so far
+var holding = make(map[reflect.Type]bool)
+
+// Gob type registry's lock
+var registeredLock sync.RWMutex
+
+// RegisterType registers the concrete type of value to travel within interface
+// arguments or results, like gob.Register does (it calls it).
+//
+// The autogenerated code registers the types of its package implementing
+// those interfaces, so users only need this for types defined elsewhere.
+func RegisterType(value interface{}) {
+	gob.Register(value)
+	markRegistered(reflect.TypeOf(value))
+}
+
+// markRegistered records t (and the type it points to) as registered for gob.
+func markRegistered(t reflect.Type) {
+	registeredLock.Lock()
+	defer registeredLock.Unlock()
+	registered[t] = true
+	if t.Kind() == reflect.Ptr {
+		registered[t.Elem()] = true
+	}
+}
+
+// known tells whether values of type t can travel within an interface.
+func known(t reflect.Type) bool {
+	registeredLock.RLock()
+	defer registeredLock.RUnlock()
+	if registered[t] {
+		return true
+	}
+	if t.PkgPath() != "" { // named types must be registered
+		return false
+	}
+	switch t.Kind() { // gob registers the basic types and slices of them by itself
+	case reflect.Slice:
+		return t.Elem().PkgPath() == "" && basic(t.Elem())
+	case reflect.Ptr:
+		return registered[t.Elem()]
+	}
+	return basic(t)
+}
+
+// basic tells whether t is of a basic kind.
+func basic(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Uintptr, reflect.Float32, reflect.Float64, reflect.Complex64,
+		reflect.Complex128, reflect.String:
+		return true
+	}
+	return false
+}
+
+// holds tells whether values of type t may hold interfaces.
+func holds(t reflect.Type) bool {
+	registeredLock.RLock()
+	h, ok := holding[t]
+	registeredLock.RUnlock()
+	if ok {
+		return h
+	}
+	h = holdsWithin(t, make(map[reflect.Type]bool))
+	registeredLock.Lock()
+	holding[t] = h
+	registeredLock.Unlock()
+	return h
+}
+
+// holdsWithin tells whether values of type t may hold interfaces, where visited are the
+// types already looked into. Those add nothing when reached again (for recursive types),
+// so results other than the outermost one are not final and must not be cached.
+func holdsWithin(t reflect.Type, visited map[reflect.Type]bool) bool {
+	if visited[t] {
+		return false
+	}
+	visited[t] = true
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return holdsWithin(t.Elem(), visited)
+	case reflect.Map:
+		return holdsWithin(t.Key(), visited) || holdsWithin(t.Elem(), visited)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if f := t.Field(i); f.PkgPath == "" && holdsWithin(f.Type, visited) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkRegistered returns an error telling the first type within v that can't
+// travel within an interface because it wasn't registered, if any.
+func checkRegistered(v interface{}) os.Error {
+	if v == nil {
+		return nil
+	}
+	return checkValue(reflect.ValueOf(v), 0)
+}
+
+// How deep checkValue looks, not to loop on cyclic values
+const maxCheckDepth = 64
+
+// checkValue checks the types held by interfaces within v.
+func checkValue(v reflect.Value, depth int) os.Error {
+	if depth > maxCheckDepth || !v.IsValid() || !holds(v.Type()) {
+		return nil
+	}
+	depth++
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		c := v.Elem()
+		if !known(c.Type()) {
+			return os.NewError(fmt.Sprintf("remotize: type %v can't travel within %v "+
+				"as it is not registered (see remotize.RegisterType)", c.Type(), v.Type()))
+		}
+		return checkValue(c, depth)
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return checkValue(v.Elem(), depth)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if e := checkValue(v.Index(i), depth); e != nil {
+				return e
+			}
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			if e := checkValue(k, depth); e != nil {
+				return e
+			}
+			if e := checkValue(v.MapIndex(k), depth); e != nil {
+				return e
+			}
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			if t.Field(i).PkgPath != "" {
+				continue
+			}
+			if e := checkValue(v.Field(i), depth); e != nil {
+				return e
+			}
+		}
+	}
+	return nil
+}
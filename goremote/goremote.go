@@ -1,14 +1,37 @@
-// The goremote command tries to remotize types from a bunch of files on the same package.
+// The goremote command tries to remotize types from a bunch of files on the same package,
+// or from many packages at once.
 //
-// Usage: 
-//   goremote <list of go files, *.go...>
+// Usage:
+//   goremote [flags] <list of go files, *.go...>
+//   goremote [flags] <package directories, dir/... for all packages within dir>
+//
+// The flags are:
+//   -type=T1,T2  remotize just the given types or interfaces among the detected ones
+//   -o=file.go   write to the given file instead of remotized<Name>.go (single type only)
+//   -iface=Name  name the interface generated for the type (single type only)
+//   -client      generate the client side code (the remote proxy) only
+//   -server      generate the server side code (the service) only
+//
+// When given package directories, each package is remotized on its own and its code is
+// generated within its directory. Each -type type must be found in one package at least.
 package main
 
 import (
 	"flag"
 	"fmt"
 	"github.com/josvazg/remotize/tool"
+	"go/build"
 	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	types  = flag.String("type", "", "comma separated types or interfaces to remotize")
+	output = flag.String("o", "", "output file name, instead of remotized<Name>.go")
+	iface  = flag.String("iface", "", "name of the interface generated for the type")
+	client = flag.Bool("client", false, "generate the client side code only")
+	server = flag.Bool("server", false, "generate the server side code only")
 )
 
 // filterRemotized will take out the remotized*.go occurrences if any
@@ -30,36 +53,204 @@ func filterRemotized(names []string) []string {
 	return result
 }
 
+// packageFiles will list the go files of the package within dir, but the remotized ones,
+// and move into dir so that the code is generated there
+func packageFiles(dir string) ([]string, os.Error) {
+	di, e := build.ScanDir(dir, true)
+	if e != nil {
+		return nil, e
+	}
+	if e := os.Chdir(dir); e != nil {
+		return nil, e
+	}
+	files := make([]string, 0)
+	for _, file := range di.GoFiles {
+		if !strings.HasPrefix(file, "remotized") {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// packageVisitor collects the directories holding go packages while walking a tree
+type packageVisitor struct {
+	root string
+	dirs []string
+	seen map[string]bool
+}
+
+// VisitDir skips hidden directories, those starting with _ and testdata ones
+func (v *packageVisitor) VisitDir(path string, f *os.FileInfo) bool {
+	return path == v.root || !(strings.HasPrefix(f.Name, ".") ||
+		strings.HasPrefix(f.Name, "_") || f.Name == "testdata")
+}
+
+// VisitFile records the directory of go source files, but tests and remotized ones
+func (v *packageVisitor) VisitFile(path string, f *os.FileInfo) {
+	if !strings.HasSuffix(f.Name, ".go") || strings.HasSuffix(f.Name, "_test.go") ||
+		strings.HasPrefix(f.Name, "remotized") {
+		return
+	}
+	dir, _ := filepath.Split(path)
+	dir = filepath.Clean(dir)
+	if !v.seen[dir] {
+		v.seen[dir] = true
+		v.dirs = append(v.dirs, dir)
+	}
+}
+
+// packageDirs returns the package directories given, where dir/... stands for all the
+// packages within dir, or nil if go files were given instead
+func packageDirs(args []string) ([]string, os.Error) {
+	dirs := make([]string, 0)
+	for _, arg := range args {
+		if arg == "..." || strings.HasSuffix(arg, "/...") {
+			root := filepath.Clean(arg[:len(arg)-len("...")] + ".")
+			v := &packageVisitor{root, make([]string, 0), make(map[string]bool)}
+			errors := make(chan os.Error)
+			walked := make(chan os.Error)
+			go func() { // drain all walk errors, keeping the first one
+				var first os.Error
+				for e := range errors {
+					if first == nil {
+						first = e
+					}
+				}
+				walked <- first
+			}()
+			filepath.Walk(v.root, v, errors)
+			close(errors)
+			if e := <-walked; e != nil {
+				return nil, e
+			}
+			dirs = append(dirs, v.dirs...)
+		} else if fi, e := os.Stat(arg); e == nil && fi.IsDirectory() {
+			dirs = append(dirs, arg)
+		} else if len(dirs) > 0 {
+			return nil, os.NewError("Can't mix package directories and go files: " + arg)
+		} else {
+			return nil, nil
+		}
+	}
+	return dirs, nil
+}
+
+// The -type types picked so far, to tell those missing from every package
+var matched = make(map[string]bool)
+
+// pick will choose the -type types among the detected ones. Missing types are an error,
+// unless many packages are being remotized, as they might be in another one. Those
+// found in no package at all are told by unmatched
+func pick(d []tool.PreSpec, many bool) ([]tool.PreSpec, os.Error) {
+	if *types == "" {
+		return d, nil
+	}
+	picked := make([]tool.PreSpec, 0)
+	for _, name := range strings.Split(*types, ",") {
+		selected, e := tool.Select(d, name)
+		if e != nil && !many {
+			return nil, e
+		}
+		if e == nil && len(selected) > 0 {
+			matched[name] = true
+		}
+		picked = append(picked, selected...)
+	}
+	return picked, nil
+}
+
+// unmatched returns the -type types not found in any package
+func unmatched() []string {
+	missing := make([]string, 0)
+	if *types == "" {
+		return missing
+	}
+	for _, name := range strings.Split(*types, ",") {
+		if !matched[name] {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
 // autoremotize will remotize all interfaces or types detected be remotizable within given files
-func autoremotize(files ...string) (int, os.Error) {
+func autoremotize(many bool, files ...string) (int, os.Error) {
 	done := 0
 	d, e := tool.Detect(files...)
 	if e != nil {
 		return 0, e
 	}
+	if d, e = pick(d, many); e != nil {
+		return 0, e
+	}
 	if d == nil || len(d) == 0 {
 		fmt.Println("No 'remotizables' found")
 		return done, nil
 	}
 	fmt.Printf("Found %v interfaces/types to remotize\n", len(d))
-	e = tool.BuildRemotizer(d)
+	cfg := &tool.Config{Output: *output, Name: *iface, Client: *client, Server: *server}
+	e = tool.BuildRemotizerWith(d, cfg)
+	if e != nil {
+		return 0, e
+	}
+	return len(d), nil
+}
+
+// remotizeDir will remotize the package within dir, generating the code there
+func remotizeDir(dir string, many bool) (int, os.Error) {
+	wd, e := os.Getwd()
 	if e != nil {
 		return 0, e
 	}
-	return done, nil
+	defer os.Chdir(wd)
+	files, e := packageFiles(dir)
+	if e != nil {
+		return 0, e
+	}
+	fmt.Println("remotize/goremote is scanning package", dir, files, "...")
+	return autoremotize(many, files...)
 }
 
 // Main invoked Autoremotize()
 func main() {
 	flag.Parse()
 	files:=filterRemotized(flag.Args())
-	if len(flag.Args()) > 0 {
+	if len(files) == 0 {
+		fmt.Println("No source files provided to remotize/goremote!")
+		fmt.Println("Usage: goremote [flags] <list of go files, *.go...> | " +
+			"<package directories, dir/...>")
+		flag.PrintDefaults()
+		return
+	}
+	dirs, e := packageDirs(files)
+	if e != nil {
+		fmt.Fprintln(os.Stderr, "remotize/goremote failed:", e)
+		os.Exit(1)
+	}
+	failed := false
+	if dirs == nil {
 		fmt.Println("remotize/goremote is scanning", files , "...")
-		autoremotize(files...)
-		fmt.Println("remotize/goremote tool ends")
+		_, e = autoremotize(false, files...)
+		failed = e != nil
 	} else {
-		fmt.Println("No source files provided to remotize/goremote!")
-		fmt.Println("Usage: goremote <list of go files, *.go...>")
+		for _, dir := range dirs {
+			if _, e = remotizeDir(dir, len(dirs) > 1); e != nil {
+				fmt.Fprintf(os.Stderr, "remotize/goremote failed on %s: %v\n", dir, e)
+				failed = true
+			}
+		}
+		for _, name := range unmatched() {
+			fmt.Fprintf(os.Stderr, "remotize/goremote failed: %s not found in any package\n",
+				name)
+			failed = true
+		}
+		e = nil
+	}
+	if e != nil {
+		fmt.Fprintln(os.Stderr, "remotize/goremote failed:", e)
+	}
+	if failed {
+		os.Exit(1)
 	}
+	fmt.Println("remotize/goremote tool ends")
 }
-
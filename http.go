@@ -0,0 +1,134 @@
+// Copyright 2011 Jose Luis Vázquez González josvazg@gmail.com
+// Use of this source code is governed by a BSD-style
+
+package remotize
+
+import (
+	"bytes"
+	"http"
+	"io"
+	"os"
+	"rpc"
+)
+
+// Content type of the calls posted by HTTPCallers
+const httpContentType = "application/x-remotize"
+
+// httpConn is the connection a codec sees for a single HTTP call.
+type httpConn struct {
+	in  io.Reader
+	out io.Writer
+}
+
+func (c *httpConn) Read(b []byte) (int, os.Error) {
+	if c.in == nil {
+		return 0, os.EOF
+	}
+	return c.in.Read(b)
+}
+
+func (c *httpConn) Write(b []byte) (int, os.Error) {
+	return c.out.Write(b)
+}
+
+func (c *httpConn) Close() os.Error {
+	return nil
+}
+
+// httpCaller posts each call to an HTTPHandler.
+type httpCaller struct {
+	t      Transport
+	url    string
+	client *http.Client
+}
+
+// NewHTTPCaller returns a Caller posting each call, encoded with transport t,
+// to the HTTPHandler at url. Calls go through the given http client, or
+// http.DefaultClient if nil, so that its Transport decides how to reach url.
+func NewHTTPCaller(t Transport, url string, client *http.Client) Caller {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &httpCaller{t, url, client}
+}
+
+func (h *httpCaller) Call(serviceMethod string, args interface{}, reply interface{}) os.Error {
+	var body bytes.Buffer
+	conn := &httpConn{out: &body}
+	codec := clientCodec(h.t, conn)
+	if e := codec.WriteRequest(&rpc.Request{ServiceMethod: serviceMethod}, args); e != nil {
+		return e
+	}
+	resp, e := h.client.Post(h.url, httpContentType, &body)
+	if e != nil {
+		return e
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return os.NewError("remotize: " + h.url + " replied " + resp.Status)
+	}
+	conn.in = resp.Body
+	var r rpc.Response
+	if e := codec.ReadResponseHeader(&r); e != nil {
+		return e
+	}
+	if r.Error != "" {
+		return rpc.ServerError(r.Error)
+	}
+	return codec.ReadResponseBody(reply)
+}
+
+func (h *httpCaller) Go(serviceMethod string, args interface{}, reply interface{},
+done chan *rpc.Call) *rpc.Call {
+	call := &rpc.Call{ServiceMethod: serviceMethod, Args: args, Reply: reply}
+	return goCall(call, done, func() os.Error {
+		return h.Call(serviceMethod, args, reply)
+	})
+}
+
+// oneCallCodec serves a single call, so that rpc.Server.ServeCodec returns
+// once that call is answered.
+type oneCallCodec struct {
+	rpc.ServerCodec
+	read    bool
+	calling bool
+	done    chan bool
+}
+
+func (c *oneCallCodec) ReadRequestHeader(r *rpc.Request) os.Error {
+	if c.read {
+		return os.EOF
+	}
+	c.read = true
+	e := c.ServerCodec.ReadRequestHeader(r)
+	c.calling = e == nil
+	return e
+}
+
+func (c *oneCallCodec) WriteResponse(r *rpc.Response, x interface{}) os.Error {
+	defer close(c.done)
+	return c.ServerCodec.WriteResponse(r, x)
+}
+
+// Close waits for the call to be answered, ServeCodec calls it right after
+// reading the request.
+func (c *oneCallCodec) Close() os.Error {
+	if c.calling {
+		<-c.done
+	}
+	return nil
+}
+
+// HTTPHandler returns an http.Handler serving srv's services to HTTPCallers
+// using transport t, one call per POST request.
+func HTTPHandler(t Transport, srv *rpc.Server) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", httpContentType)
+		codec := serverCodec(t, &httpConn{r.Body, w})
+		srv.ServeCodec(&oneCallCodec{ServerCodec: codec, done: make(chan bool)})
+	})
+}
@@ -0,0 +1,151 @@
+// Copyright 2011 Jose Luis Vázquez González josvazg@gmail.com
+// Use of this source code is governed by a BSD-style
+
+package remotize
+
+import (
+	"os"
+	"strings"
+	"sync"
+)
+
+// ServerCall describes a call being served by an autogenerated service.
+type ServerCall struct {
+	// Service is the service name, like "URLStorerService"
+	Service string
+	// Method is the method name, like "Get"
+	Method string
+	// Args are the decoded args, as a pointer to the autogenerated Args struct
+	Args interface{}
+	// Reply is the reply to be sent, filled once the call is done
+	Reply interface{}
+}
+
+// ServerInterceptor is called around every call served by the autogenerated
+// services. It must call next to go on serving the call (through the rest of
+// the chain and the implementation) and return its error, or return an error
+// on its own to refuse the call.
+type ServerInterceptor func(call *ServerCall, next func() os.Error) os.Error
+
+// Server interceptor chain, outermost first
+var serverInterceptors []ServerInterceptor
+
+// Server interceptor chain's lock
+var serverInterceptorsLock sync.RWMutex
+
+// AddServerInterceptor appends i to the chain every autogenerated service
+// call passes through. Interceptors added first are run outermost.
+func AddServerInterceptor(i ServerInterceptor) {
+	serverInterceptorsLock.Lock()
+	defer serverInterceptorsLock.Unlock()
+	serverInterceptors = append(serverInterceptors, i)
+}
+
+// ServeCall serves a call to service's method through the server interceptor
+// chain, ending up running call. Panics are recovered and returned as a
+// PanicError, and replies holding unregistered types within interfaces are
+// refused.
+//
+// Users DON'T need to care about this, as it is called by the autogenerated code.
+func ServeCall(service, method string, args interface{}, reply interface{},
+call func() os.Error) (e os.Error) {
+	defer func() {
+		if v := recover(); v != nil {
+			e = recovered(service+"."+method, v)
+		}
+	}()
+	serverInterceptorsLock.RLock()
+	chain := serverInterceptors
+	serverInterceptorsLock.RUnlock()
+	sc := &ServerCall{service, method, args, reply}
+	if e := serveThrough(chain, sc, call); e != nil {
+		return e
+	}
+	return checkRegistered(reply)
+}
+
+// serveThrough runs call through the given interceptors.
+func serveThrough(chain []ServerInterceptor, sc *ServerCall, call func() os.Error) os.Error {
+	if len(chain) == 0 {
+		return call()
+	}
+	return chain[0](sc, func() os.Error {
+		return serveThrough(chain[1:], sc, call)
+	})
+}
+
+// ClientCall describes a call being made by an autogenerated remote proxy.
+type ClientCall struct {
+	// Service is the service name, like "URLStorerService"
+	Service string
+	// Method is the method name, like "Get"
+	Method string
+	// Args are the args to be sent, as a pointer to the autogenerated Args struct
+	Args interface{}
+	// Reply is the reply, filled once the call is done
+	Reply interface{}
+}
+
+// ClientInterceptor is called around every call attempt made by the
+// autogenerated remote proxies. It must call next to go on with the call
+// (through the rest of the chain and the transport) and return its error, or
+// return an error on its own to fail the attempt.
+type ClientInterceptor func(call *ClientCall, next func() os.Error) os.Error
+
+// Client interceptor chain, outermost first
+var clientInterceptors []ClientInterceptor
+
+// Client interceptor chain's lock
+var clientInterceptorsLock sync.RWMutex
+
+// AddClientInterceptor appends i to the chain every autogenerated remote proxy
+// call passes through. Interceptors added first are run outermost, and all of
+// them run outside the ones set on each proxy by WithInterceptor.
+func AddClientInterceptor(i ClientInterceptor) {
+	clientInterceptorsLock.Lock()
+	defer clientInterceptorsLock.Unlock()
+	clientInterceptors = append(clientInterceptors, i)
+}
+
+// WithInterceptor appends i to the proxy's own client interceptor chain.
+func WithInterceptor(i ClientInterceptor) Option {
+	return func(o *Options) {
+		// copied, as options may be inherited
+		o.Interceptors = append(append([]ClientInterceptor{}, o.Interceptors...), i)
+	}
+}
+
+// splitServiceMethod splits "Service.Method" into its service and method names.
+func splitServiceMethod(serviceMethod string) (string, string) {
+	dot := strings.LastIndex(serviceMethod, ".")
+	if dot < 0 {
+		return "", serviceMethod
+	}
+	return serviceMethod[:dot], serviceMethod[dot+1:]
+}
+
+// intercept runs call through the client interceptor chains of o.
+func (o *Options) intercept(serviceMethod string, args interface{}, reply interface{},
+call func() os.Error) os.Error {
+	clientInterceptorsLock.RLock()
+	chain := clientInterceptors
+	clientInterceptorsLock.RUnlock()
+	if len(o.Interceptors) > 0 {
+		chain = append(append([]ClientInterceptor{}, chain...), o.Interceptors...)
+	}
+	if len(chain) == 0 {
+		return call()
+	}
+	service, method := splitServiceMethod(serviceMethod)
+	return callThrough(chain, &ClientCall{service, method, args, reply}, call)
+}
+
+// callThrough runs call through the given interceptors.
+func callThrough(chain []ClientInterceptor, cc *ClientCall, call func() os.Error) os.Error {
+	if len(chain) == 0 {
+		return call()
+	}
+	return chain[0](cc, func() os.Error {
+		return callThrough(chain[1:], cc, call)
+	})
+}
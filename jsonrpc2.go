@@ -0,0 +1,214 @@
+// Copyright 2011 Jose Luis Vázquez González josvazg@gmail.com
+// Use of this source code is governed by a BSD-style
+
+package remotize
+
+import (
+	"bytes"
+	"io"
+	"json"
+	"os"
+	"reflect"
+	"rpc"
+	"strings"
+	"sync"
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	json2MethodNotFound = -32601
+	json2ServerError    = -32000
+)
+
+// json2Request is a JSON-RPC 2.0 request as received by the server.
+type json2Request struct {
+	Version string           `json:"jsonrpc"`
+	Method  string           `json:"method"`
+	Params  *json.RawMessage `json:"params"`
+	Id      *json.RawMessage `json:"id"`
+}
+
+// json2Error is the error object of a failed JSON-RPC 2.0 call.
+type json2Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// json2Result is a successful JSON-RPC 2.0 response as sent by the server.
+type json2Result struct {
+	Version string           `json:"jsonrpc"`
+	Result  interface{}      `json:"result"`
+	Id      *json.RawMessage `json:"id"`
+}
+
+// json2Failure is a failed JSON-RPC 2.0 response as sent by the server.
+type json2Failure struct {
+	Version string           `json:"jsonrpc"`
+	Error   *json2Error      `json:"error"`
+	Id      *json.RawMessage `json:"id"`
+}
+
+// json2ServerCodec implements rpc.ServerCodec for JSON-RPC 2.0.
+type json2ServerCodec struct {
+	dec     *json.Decoder
+	enc     *json.Encoder
+	c       io.Closer
+	req     json2Request
+	mutex   sync.Mutex // guards seq and pending
+	seq     uint64
+	pending map[uint64]*json.RawMessage
+}
+
+// NewJSON2ServerCodec returns a new rpc.ServerCodec speaking JSON-RPC 2.0
+// on conn.
+//
+// Params may be given by name, as an object with the fields of the autogenerated
+// Args struct (tagged as the method parameters, or arg0, arg1... if unnamed), or
+// by position, as an array with the values of those fields in order. Requests
+// without id are notifications and get no response.
+func NewJSON2ServerCodec(conn io.ReadWriteCloser) rpc.ServerCodec {
+	return &json2ServerCodec{dec: json.NewDecoder(conn), enc: json.NewEncoder(conn),
+		c: conn, pending: make(map[uint64]*json.RawMessage)}
+}
+
+func (c *json2ServerCodec) ReadRequestHeader(r *rpc.Request) os.Error {
+	c.req = json2Request{}
+	if e := c.dec.Decode(&c.req); e != nil {
+		return e
+	}
+	r.ServiceMethod = c.req.Method
+	c.mutex.Lock()
+	c.seq++
+	c.pending[c.seq] = c.req.Id
+	r.Seq = c.seq
+	c.mutex.Unlock()
+	return nil
+}
+
+func (c *json2ServerCodec) ReadRequestBody(x interface{}) os.Error {
+	if x == nil || c.req.Params == nil {
+		return nil
+	}
+	params := bytes.TrimSpace([]byte(*c.req.Params))
+	if len(params) > 0 && params[0] == '[' {
+		return unmarshalPositional(params, x)
+	}
+	return json.Unmarshal(params, x)
+}
+
+// unmarshalPositional decodes a JSON array of params into x. When x points to a
+// struct, as the autogenerated Args, each value goes to the field in the same
+// position. Otherwise the array must hold x as its only value.
+func unmarshalPositional(params []byte, x interface{}) os.Error {
+	var values []*json.RawMessage
+	if e := json.Unmarshal(params, &values); e != nil {
+		return e
+	}
+	v := reflect.ValueOf(x)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		if len(values) != 1 || values[0] == nil {
+			return os.NewError("remotize: expected a single positional param")
+		}
+		return json.Unmarshal([]byte(*values[0]), x)
+	}
+	st := v.Elem()
+	if len(values) > st.NumField() {
+		return os.NewError("remotize: too many positional params")
+	}
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+		e := json.Unmarshal([]byte(*value), st.Field(i).Addr().Interface())
+		if e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+func (c *json2ServerCodec) WriteResponse(r *rpc.Response, x interface{}) os.Error {
+	c.mutex.Lock()
+	id, ok := c.pending[r.Seq]
+	c.pending[r.Seq] = nil, false
+	c.mutex.Unlock()
+	if !ok {
+		return os.NewError("remotize: invalid sequence number in response")
+	}
+	if id == nil { // notification
+		return nil
+	}
+	if r.Error == "" {
+		return c.enc.Encode(&json2Result{"2.0", x, id})
+	}
+	code := json2ServerError
+	if strings.HasPrefix(r.Error, "rpc: can't find") {
+		code = json2MethodNotFound
+	}
+	return c.enc.Encode(&json2Failure{"2.0", &json2Error{code, r.Error}, id})
+}
+
+func (c *json2ServerCodec) Close() os.Error {
+	return c.c.Close()
+}
+
+// json2ClientRequest is a JSON-RPC 2.0 request as sent by the client.
+type json2ClientRequest struct {
+	Version string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+	Id      uint64      `json:"id"`
+}
+
+// json2Response is a JSON-RPC 2.0 response as received by the client.
+type json2Response struct {
+	Version string           `json:"jsonrpc"`
+	Result  *json.RawMessage `json:"result"`
+	Error   *json2Error      `json:"error"`
+	Id      uint64           `json:"id"`
+}
+
+// json2ClientCodec implements rpc.ClientCodec for JSON-RPC 2.0.
+type json2ClientCodec struct {
+	dec  *json.Decoder
+	enc  *json.Encoder
+	c    io.Closer
+	resp json2Response
+}
+
+// NewJSON2ClientCodec returns a new rpc.ClientCodec speaking JSON-RPC 2.0
+// on conn. Params are sent by name.
+func NewJSON2ClientCodec(conn io.ReadWriteCloser) rpc.ClientCodec {
+	return &json2ClientCodec{dec: json.NewDecoder(conn), enc: json.NewEncoder(conn), c: conn}
+}
+
+func (c *json2ClientCodec) WriteRequest(r *rpc.Request, param interface{}) os.Error {
+	return c.enc.Encode(&json2ClientRequest{"2.0", r.ServiceMethod, param, r.Seq})
+}
+
+func (c *json2ClientCodec) ReadResponseHeader(r *rpc.Response) os.Error {
+	c.resp = json2Response{}
+	if e := c.dec.Decode(&c.resp); e != nil {
+		return e
+	}
+	r.Seq = c.resp.Id
+	r.Error = ""
+	if c.resp.Error != nil {
+		r.Error = c.resp.Error.Message
+		if r.Error == "" {
+			r.Error = "unspecified error"
+		}
+	}
+	return nil
+}
+
+func (c *json2ClientCodec) ReadResponseBody(x interface{}) os.Error {
+	if x == nil || c.resp.Result == nil {
+		return nil
+	}
+	return json.Unmarshal([]byte(*c.resp.Result), x)
+}
+
+func (c *json2ClientCodec) Close() os.Error {
+	return c.c.Close()
+}
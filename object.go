@@ -0,0 +1,57 @@
+// Copyright 2011 Jose Luis Vázquez González josvazg@gmail.com
+// Use of this source code is governed by a BSD-style
+
+package remotize
+
+import (
+	"os"
+	"rpc"
+)
+
+// releaser is implemented by all autogenerated remote proxies.
+type releaser interface {
+	RemotizeRelease() os.Error
+}
+
+// ServeObject serves the service wrapper of an object returned by a remotized
+// method on a new stream, so that the client can reach it by the returned id.
+//
+// The object is kept until the client releases it or its connection drops.
+//
+// Users DON'T need to care about this, as it is called by the autogenerated code.
+func ServeObject(service interface{}) (uint64, os.Error) {
+	if service == nil {
+		return 0, os.NewError("remotize: there is no service to serve the object with")
+	}
+	srv := rpc.NewServer()
+	if e := srv.Register(service); e != nil {
+		return 0, e
+	}
+	s := NewStream()
+	go srv.ServeConn(s)
+	return s.Id(), nil
+}
+
+// RemoteObject returns a proxy of interface type iface, tuned by opts, to the
+// object served through stream id on the given service.
+//
+// Users DON'T need to care about this, as it is called by the autogenerated code.
+func RemoteObject(cli Caller, service string, id uint64, iface interface{},
+opts ...Option) (interface{}, os.Error) {
+	ocli := rpc.NewClient(DialStream(cli, service, id))
+	r, e := newRemote(ocli, iface, opts...)
+	if e != nil {
+		ocli.Close()
+		return nil, e
+	}
+	return r, nil
+}
+
+// Release closes the connection of a remote proxy, releasing the remote
+// object behind it, if any.
+func Release(remote interface{}) os.Error {
+	if r, ok := remote.(releaser); ok {
+		return r.RemotizeRelease()
+	}
+	return os.NewError("remotize: can't release a non remote proxy")
+}
@@ -0,0 +1,74 @@
+// Copyright 2011 Jose Luis Vázquez González josvazg@gmail.com
+// Use of this source code is governed by a BSD-style
+
+package remotize
+
+import (
+	"log"
+	"os"
+)
+
+// ErrorHandler is called by a remote proxy when a call fails on a method that
+// has no error result to report the failure with.
+type ErrorHandler func(method string, e os.Error)
+
+// PanicOnError is the default ErrorHandler, it just panics with the error.
+func PanicOnError(method string, e os.Error) {
+	panic(method + ": " + e.String())
+}
+
+// LogOnError is an ErrorHandler that logs the error and lets the method
+// return zero values.
+func LogOnError(method string, e os.Error) {
+	log.Printf("remotize: %s failed: %v", method, e)
+}
+
+// Options holds the settings for a remote proxy.
+//
+// Users DON'T need to care about the Options struct, they set them up by
+// passing Option values to NewRemote or the autogenerated NewRemoteXXX calls.
+type Options struct {
+	// OnError handles failures on methods that can't return errors
+	OnError ErrorHandler
+	// Policies holds the call policy for each method having its own
+	Policies map[string]Policy
+	// Default is the call policy for the rest of methods
+	Default Policy
+	// Interceptors is the proxy's own client interceptor chain
+	Interceptors []ClientInterceptor
+	// RePanic tells whether to re-panic when the remote implementation panicked
+	RePanic bool
+}
+
+// Option sets up some setting on the Options for a remote proxy.
+type Option func(*Options)
+
+// WithErrorHandler sets h as the proxy's ErrorHandler.
+func WithErrorHandler(h ErrorHandler) Option {
+	return func(o *Options) {
+		o.OnError = h
+	}
+}
+
+// Inherit copies all settings from o.
+func Inherit(o *Options) Option {
+	return func(n *Options) {
+		*n = *o
+	}
+}
+
+// NewOptions returns the default Options modified by the given opts.
+func NewOptions(opts ...Option) *Options {
+	o := &Options{OnError: PanicOnError}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Fail reports a call failure on a method without an error result.
+func (o *Options) Fail(method string, e os.Error) {
+	if o.OnError != nil {
+		o.OnError(method, e)
+	}
+}
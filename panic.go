@@ -0,0 +1,97 @@
+// Copyright 2011 Jose Luis Vázquez González josvazg@gmail.com
+// Use of this source code is governed by a BSD-style
+
+package remotize
+
+import (
+	"fmt"
+	"os"
+	"rpc"
+	"runtime/debug"
+	"strings"
+)
+
+// PanicStacks tells whether the autogenerated services send the server stack
+// trace along with the panics they recover. They don't by default.
+var PanicStacks = false
+
+// How a recovered panic is told within a rpc error
+const (
+	panicPrefix = "remotize: "
+	panicInfix  = " panicked: "
+	stackInfix  = "\n\n"
+)
+
+// PanicError is the failure of a remote call whose implementation panicked.
+//
+// Autogenerated services recover the panic and send it to the client, where
+// it is returned as the call failure (or re-panicked, see WithRePanic).
+type PanicError struct {
+	// Method is the service method that panicked, like "URLStorerService.Get"
+	Method string
+	// Value is the panic value, as printed by fmt
+	Value string
+	// Stack is the server stack trace, if PanicStacks was set
+	Stack string
+}
+
+// String returns the panic error message, which is also how it travels.
+func (p *PanicError) String() string {
+	s := panicPrefix + p.Method + panicInfix + p.Value
+	if p.Stack != "" {
+		s += stackInfix + p.Stack
+	}
+	return s
+}
+
+// recovered turns the panic value recovered while serving method into a
+// PanicError.
+func recovered(method string, v interface{}) *PanicError {
+	p := &PanicError{Method: method, Value: fmt.Sprint(v)}
+	if PanicStacks {
+		p.Stack = string(debug.Stack())
+	}
+	return p
+}
+
+// AsPanic returns the PanicError behind a remote call failure e, or nil if
+// the implementation didn't panic.
+func AsPanic(e os.Error) *PanicError {
+	switch err := e.(type) {
+	case *PanicError:
+		return err
+	case rpc.ServerError:
+		s := string(err)
+		if !strings.HasPrefix(s, panicPrefix) {
+			return nil
+		}
+		s = s[len(panicPrefix):]
+		at := strings.Index(s, panicInfix)
+		if at < 0 {
+			return nil
+		}
+		p := &PanicError{Method: s[:at], Value: s[at+len(panicInfix):]}
+		if at := strings.Index(p.Value, stackInfix); at >= 0 {
+			p.Value, p.Stack = p.Value[:at], p.Value[at+len(stackInfix):]
+		}
+		return p
+	}
+	return nil
+}
+
+// remoteError returns the failure told by a rpc error message.
+func remoteError(s string) os.Error {
+	e := rpc.ServerError(s)
+	if p := AsPanic(e); p != nil {
+		return p
+	}
+	return e
+}
+
+// WithRePanic makes the proxy re-panic with the PanicError of calls whose
+// implementation panicked, instead of failing them.
+func WithRePanic() Option {
+	return func(o *Options) {
+		o.RePanic = true
+	}
+}
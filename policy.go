@@ -0,0 +1,172 @@
+// Copyright 2011 Jose Luis Vázquez González josvazg@gmail.com
+// Use of this source code is governed by a BSD-style
+
+package remotize
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"rpc"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrTimeout is returned by calls taking longer than their policy allows.
+var ErrTimeout = os.NewError("remotize: call timed out")
+
+// Policy tells a remote proxy how to call a method.
+//
+// Only idempotent methods are retried, and only when the call didn't reach
+// the implementation or its answer was lost, never when the implementation
+// itself failed.
+type Policy struct {
+	// Timeout is how long, in nanoseconds, a call may take (0 means forever)
+	Timeout int64
+	// Retries is how many times a failed call is retried
+	Retries int
+	// Backoff is how long, in nanoseconds, to wait before the first retry,
+	// doubling before each of the next ones
+	Backoff int64
+	// Idempotent tells whether the method can be safely called again
+	Idempotent bool
+}
+
+// Time units for policy durations, in nanoseconds
+var units = map[string]int64{
+	"ns": 1, "us": 1e3, "ms": 1e6, "s": 1e9, "m": 60e9, "h": 3600e9,
+}
+
+// ParsePolicy parses policy settings as written within a method annotation,
+// like "idempotent timeout=2s retries=3 backoff=100ms".
+//
+// Durations are a number followed by one of the units: ns, us, ms, s, m or h.
+func ParsePolicy(settings string) (Policy, os.Error) {
+	var p Policy
+	settings = strings.TrimLeft(strings.TrimSpace(settings), ":")
+	for _, setting := range strings.Fields(strings.Replace(settings, ",", " ", -1)) {
+		kv := strings.SplitN(setting, "=", 2)
+		var e os.Error
+		switch {
+		case len(kv) == 1 && kv[0] == "idempotent":
+			p.Idempotent = true
+		case len(kv) == 2 && kv[0] == "timeout":
+			p.Timeout, e = parseDuration(kv[1])
+		case len(kv) == 2 && kv[0] == "backoff":
+			p.Backoff, e = parseDuration(kv[1])
+		case len(kv) == 2 && kv[0] == "retries":
+			p.Retries, e = strconv.Atoi(kv[1])
+		default:
+			e = os.NewError("unknown setting")
+		}
+		if e != nil {
+			return p, os.NewError(fmt.Sprintf("remotize: bad policy setting '%s': %v",
+				setting, e))
+		}
+	}
+	return p, nil
+}
+
+// parseDuration parses a duration like 2s or 1.5ms into nanoseconds.
+func parseDuration(s string) (int64, os.Error) {
+	i := strings.IndexFunc(s, func(c int) bool {
+		return (c < '0' || c > '9') && c != '.'
+	})
+	if i <= 0 {
+		return 0, os.NewError("duration needs a number and a unit")
+	}
+	unit, ok := units[s[i:]]
+	if !ok {
+		return 0, os.NewError("unknown time unit " + s[i:])
+	}
+	n, e := strconv.Atof64(s[:i])
+	if e != nil {
+		return 0, e
+	}
+	return int64(n * float64(unit)), nil
+}
+
+// WithPolicy sets the call policy for method, or the default policy for all
+// methods without their own if method is empty.
+func WithPolicy(method string, p Policy) Option {
+	return func(o *Options) {
+		if method == "" {
+			o.Default = p
+			return
+		}
+		policies := make(map[string]Policy) // copied, as options may be inherited
+		for m, mp := range o.Policies {
+			policies[m] = mp
+		}
+		policies[method] = p
+		o.Policies = policies
+	}
+}
+
+// policy returns the policy for serviceMethod.
+func (o *Options) policy(serviceMethod string) Policy {
+	_, method := splitServiceMethod(serviceMethod)
+	if p, ok := o.Policies[method]; ok {
+		return p
+	}
+	return o.Default
+}
+
+// Call calls serviceMethod through c as its policy says. Each call attempt
+// passes through the client interceptor chains.
+//
+// Users DON'T need to care about this, as it is called by the autogenerated code.
+func (o *Options) Call(c Caller, serviceMethod string, args interface{}, reply interface{}) os.Error {
+	if e := checkRegistered(args); e != nil {
+		return e
+	}
+	p := o.policy(serviceMethod)
+	attempt := func() os.Error {
+		return o.intercept(serviceMethod, args, reply, func() os.Error {
+			return p.call(c, serviceMethod, args, reply)
+		})
+	}
+	e := attempt()
+	backoff := p.Backoff
+	for retry := 0; e != nil && p.Idempotent && retry < p.Retries && retriable(e); retry++ {
+		if backoff > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		e = attempt()
+	}
+	if p := AsPanic(e); p != nil {
+		if o.RePanic {
+			panic(p)
+		}
+		return p
+	}
+	return e
+}
+
+// call makes a single call attempt within the policy timeout.
+func (p Policy) call(c Caller, serviceMethod string, args interface{}, reply interface{}) os.Error {
+	if p.Timeout <= 0 {
+		return c.Call(serviceMethod, args, reply)
+	}
+	// a late reply must not be written over the one of another attempt
+	attempt := reflect.New(reflect.TypeOf(reply).Elem())
+	call := c.Go(serviceMethod, args, attempt.Interface(), make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+		if call.Error == nil {
+			reflect.ValueOf(reply).Elem().Set(attempt.Elem())
+		}
+		return call.Error
+	case <-time.After(p.Timeout):
+	}
+	return ErrTimeout
+}
+
+// retriable tells whether a failed call may be retried, that is, whether the
+// implementation didn't fail itself.
+func retriable(e os.Error) bool {
+	_, failed := e.(rpc.ServerError)
+	return !failed
+}
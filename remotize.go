@@ -11,14 +11,20 @@ package remotize
 
 import (
 	"fmt"
-	"rpc"
+	"os"
 	"reflect"
 	"strings"
 	"sync"
 )
 
-// Remotized Registry
-var registry = make(map[string]interface{})
+// Remotized Registry, by interface type
+var registry = make(map[reflect.Type]*builders)
+
+// builders holds the builders autogenerated for an interface
+type builders struct {
+	remote  BuildRemote
+	service BuildService
+}
 
 // Registry's lock
 var lock sync.RWMutex
@@ -30,11 +36,11 @@ var lock sync.RWMutex
 type BuildService func(interface{}) interface{}
 
 // BuildRemote builds a local reference to a remote interface reachable through
-// a given RpcClient.
+// a given Caller.
 //
 // Users DON'T need to care about this, as it is done for them by the 
 // autogenerated code and will be invoked as appropiate when calling NewRemote.
-type BuildRemote func(*rpc.Client) interface{}
+type BuildRemote func(Caller, ...Option) interface{}
 
 // Please does nothing. It's just a marker that tells the remotize tool 
 // (goremote) that i interface must, "please", be remotized:
@@ -47,85 +53,169 @@ func Please(i interface{}) {
 	// Nothing to do, just a marker
 }
 
-// NewService returns a new service wrapper to serve calls to 'ifaceimpl' from remote rpc clients.
+// NewService returns a new service wrapper to serve calls to 'ifaceimpl' from remote rpc clients,
+// or nil if no remotized interface implemented by 'ifaceimpl' was registered (see ServiceOf).
+// When 'ifaceimpl' implements many, the one named after its type is served, as URLStorer for
+// URLStore, or nil is returned if none is named so.
 func NewService(ifaceimpl interface{}) interface{} {
 	return NewServiceWith(ifaceimpl, ifaceimpl)
 }
 
-// NewServiceWith returns a new service wrapper to call 'impl', with interface 'iface'.
+// NewServiceWith returns a new service wrapper to call 'impl', with interface 'iface', or nil if
+// no such remotized interface was registered.
 func NewServiceWith(iface interface{}, impl interface{}) interface{} {
-	p := RegistryFind(searchName("", nameFor(iface)) + "Service")
-	if p == nil {
-		return nil
+	s, _ := newService(iface, impl)
+	return s
+}
+
+// ServiceOf returns a new service wrapper to serve calls to impl from remote rpc clients, for
+// the registered remotized interface named after its type or, if there's none, the only one
+// impl implements, or an error telling why there's none.
+func ServiceOf(impl interface{}) (interface{}, os.Error) {
+	return newService(impl, impl)
+}
+
+// newService returns a new service wrapper to call impl, with interface iface.
+func newService(iface interface{}, impl interface{}) (interface{}, os.Error) {
+	r, e := lookup(iface)
+	if e != nil {
+		return nil, e
 	}
-	return p.(BuildService)(impl)
+	if r.service == nil {
+		return nil, os.NewError(fmt.Sprintf("remotize: %v was remotized without its service "+
+			"side", reflect.TypeOf(iface)))
+	}
+	return r.service(impl), nil
 }
 
 // NewRemote returns a proxy to a remote interface of type iface,
-// reachable through c RpcClient.
-func NewRemote(c *rpc.Client, iface interface{}) interface{} {
-	p := RegistryFind(searchName("Remote", nameFor(iface)))
-	if p == nil {
-		return nil
+// reachable through c Caller (like a *rpc.Client), or nil if no such remotized interface 
+// was registered. The proxy can be tuned with some opts, like WithErrorHandler.
+func NewRemote(c Caller, iface interface{}, opts ...Option) interface{} {
+	r, _ := newRemote(c, iface, opts...)
+	return r
+}
+
+// RemoteOf sets the interface variable pointed by ptr to a proxy of the remote interface
+// reachable through c Caller, as NewRemote does, so that no type assertion is needed:
+//
+//  var urlstore URLStorer
+//  err := remotize.RemoteOf(rpcClient, &urlstore)
+//
+// It fails telling why if no such remotized interface was registered.
+func RemoteOf(c Caller, ptr interface{}, opts ...Option) os.Error {
+	v := reflect.ValueOf(ptr)
+	if !v.IsValid() || v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Interface {
+		return os.NewError(fmt.Sprintf("remotize: RemoteOf needs a pointer to an interface "+
+			"variable, not %T", ptr))
+	}
+	r, e := newRemote(c, ptr, opts...)
+	if e != nil {
+		return e
 	}
-	return p.(BuildRemote)(c)
+	v.Elem().Set(reflect.ValueOf(r))
+	return nil
 }
 
-// nameFor returns the name of the given underliying type. Pointers are followed
-// up to the final referenced type.
-func nameFor(i interface{}) string {
-	t := reflect.TypeOf(i)
-	for t.Kind() == reflect.Ptr {
-		t = (t).Elem()
+// newRemote returns a proxy to a remote interface of type iface reachable through c.
+func newRemote(c Caller, iface interface{}, opts ...Option) (interface{}, os.Error) {
+	r, e := lookup(iface)
+	if e != nil {
+		return nil, e
 	}
-	return fmt.Sprintf("%v", t)
+	if r.remote == nil {
+		return nil, os.NewError(fmt.Sprintf("remotize: %v was remotized without its client "+
+			"side", reflect.TypeOf(iface)))
+	}
+	return r.remote(c, opts...), nil
 }
 
-// searchName will search a prefix and name on the registry.
-func searchName(prefix, ifacename string) string {
-	parts := strings.Split(ifacename, ".")
-	if len(parts) == 2 {
-		p := ""
-		if !strings.HasPrefix(parts[1], prefix) {
-			p = prefix
+// lookup finds the registered builders for i, which is either a pointer to a remotized
+// interface or a value implementing one of them. A value implementing many gets the one
+// named after its type, as URLStorer for URLStore, if it was registered.
+func lookup(i interface{}) (*builders, os.Error) {
+	if i == nil {
+		return nil, os.NewError("remotize: can't find the remotized interface of nil")
+	}
+	t := reflect.TypeOf(i)
+	it := t
+	for it.Kind() == reflect.Ptr {
+		it = it.Elem()
+	}
+	lock.RLock()
+	defer lock.RUnlock()
+	if it.Kind() == reflect.Interface {
+		if r, ok := registry[it]; ok {
+			return r, nil
+		}
+		return nil, os.NewError(fmt.Sprintf("remotize: interface %v is not remotized "+
+			"(was goremote run on its package?)", it))
+	}
+	name := it.Name() + Suffix(it.Name())
+	for iface, r := range registry {
+		if iface.Name() == name && iface.PkgPath() == it.PkgPath() && t.Implements(iface) {
+			return r, nil
 		}
-		return parts[0] + "." + p + parts[1] + ifaceSuffix(ifacename)
 	}
-	return ifacename + ifaceSuffix(ifacename)
+	var found reflect.Type
+	for iface, _ := range registry {
+		if !t.Implements(iface) {
+			continue
+		}
+		if found != nil {
+			return nil, os.NewError(fmt.Sprintf("remotize: %v implements both %v and %v, "+
+				"choose one with NewServiceWith", t, found, iface))
+		}
+		found = iface
+	}
+	if found == nil {
+		return nil, os.NewError(fmt.Sprintf("remotize: %v implements no remotized interface", t))
+	}
+	return registry[found], nil
 }
 
-// Register will record a local reference to a remote interface 'r', 
-// its builder 'br', the corresponding service 's' and its builder, so that they
-// can be retrieved later by NewRemote or NewService calls respectively.
+// Register will record the builders of a local reference to a remote interface 'iface'
+// and of the corresponding service, so that they can be retrieved later by NewRemote or
+// NewService calls respectively. 'iface' is a pointer to the interface, like new(URLStorer).
+//
+// Either builder may be nil, when the code was generated for the other side only.
 //
 // Users DON'T need to care about this registration, as it is done by the 
 // autogenerated code for them.
-func Register(r interface{}, br BuildRemote, s interface{}, bs BuildService) {
-	cname := fmt.Sprintf("%v", reflect.TypeOf(r))
-	sname := fmt.Sprintf("%v", reflect.TypeOf(s))
+func Register(iface interface{}, br BuildRemote, bs BuildService) {
+	t := reflect.TypeOf(iface)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
 	lock.Lock()
 	defer lock.Unlock()
-	registry[cname] = br
-	registry[sname] = bs
+	registry[t] = &builders{br, bs}
 }
 
 // RegistryDump dumps the contents of the registry for debugging purposes.
 func RegistryDump() string {
-	var s string
-	fmt.Sprintf(s, "%v", registry)
+	lock.RLock()
+	defer lock.RUnlock()
+	s := ""
+	for t, r := range registry {
+		s += fmt.Sprintf("%v: remote=%v service=%v\n", t, r.remote != nil, r.service != nil)
+	}
 	return s
 }
 
-// RegistryFind will find a registered name in the remotize registry.
-func RegistryFind(name string) interface{} {
-	lock.Lock()
-	defer lock.Unlock()
-	return registry[name]
+// RegistryFind will find the builders registered for a remotized interface, given as a
+// pointer to it, like new(URLStorer). They are nil if not found.
+func RegistryFind(iface interface{}) (BuildRemote, BuildService) {
+	r, e := lookup(iface)
+	if e != nil {
+		return nil, nil
+	}
+	return r.remote, r.service
 }
 
-// IfaceSuffix will return the proper "r" or "er" or "" ending as an interface for 
+// Suffix will return the proper "r" or "er" or "" ending as an interface for 
 // the given 'name'.
-func ifaceSuffix(name string) string {
+func Suffix(name string) string {
 	s := ""
 	if !strings.HasSuffix(name, "er") {
 		if endsWithVowel(name) {
@@ -1,23 +1,38 @@
 package remotize
 
 import (
+	"fmt"
+	"http"
+	"io"
+	"json"
+	"net"
+	"os"
 	"reflect"
 	"rpc"
 	"testing"
+	"time"
 )
 
 type Sometyper interface {
-
+	Sometype()
 }
 
 type RemoteSometyper struct {
 
 }
 
+func (r *RemoteSometyper) Sometype() {}
+
 type SometyperService struct {
 
 }
 
+type sometype struct {
+
+}
+
+func (s *sometype) Sometype() {}
+
 func checkType(t *testing.T, typename string, i interface{}) {
 	if i == nil {
 		t.Fatal("Could not retrieve " + typename + "!")
@@ -29,17 +44,879 @@ func checkType(t *testing.T, typename string, i interface{}) {
 }
 
 func TestRegistry(t *testing.T) {
-	Register(RemoteSometyper{}, 
-		func (*rpc.Client) interface{} {
+	Register(new(Sometyper),
+		func (Caller, ...Option) interface{} {
 			return &RemoteSometyper{}
 		},
-		SometyperService{}, 
 		func (interface{}) interface{} {
 			return &SometyperService{}
 		})
 	s := NewService(new(Sometyper))
 	checkType(t, "SometyperService", s)
+	s, e := ServiceOf(new(sometype))
+	if e != nil {
+		t.Fatal(e)
+	}
+	checkType(t, "SometyperService", s)
 	r := NewRemote(nil, new(Sometyper))
 	checkType(t, "RemoteSometyper", r)
+	var st Sometyper
+	if e := RemoteOf(nil, &st); e != nil {
+		t.Fatal(e)
+	}
+	checkType(t, "RemoteSometyper", st)
+	var rc io.ReadCloser
+	if e := RemoteOf(nil, &rc); e == nil {
+		t.Fatal("Expected an error getting a proxy of a non remotized interface!")
+	}
+	if _, e := ServiceOf(new(Echoer)); e == nil {
+		t.Fatal("Expected an error serving a type implementing no remotized interface!")
+	}
+	if e := RemoteOf(nil, nil); e == nil {
+		t.Fatal("Expected an error getting a proxy into nil!")
+	}
+}
+
+type Bother interface {
+	Sometype()
+}
+
+type BotherService struct{}
+
+type Both struct{}
+
+func (b *Both) Sometype() {}
+
+func TestRegistryNamed(t *testing.T) {
+	Register(new(Bother), nil, func(interface{}) interface{} {
+		return &BotherService{}
+	})
+	// Both implements Sometyper as well, but Bother is named after it
+	checkType(t, "BotherService", NewService(new(Both)))
+	if _, e := ServiceOf(new(sometype)); e == nil {
+		t.Fatal("Expected an error serving a type implementing two remotized interfaces!")
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+	failed := ""
+	o := NewOptions(WithErrorHandler(func(method string, e os.Error) {
+		failed = method
+	}))
+	o.Fail("SometyperService.Op", os.NewError("some failure"))
+	if failed != "SometyperService.Op" {
+		t.Fatal("Expected the ErrorHandler to be called for 'SometyperService.Op'!")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Expected the default ErrorHandler to panic!")
+		}
+	}()
+	NewOptions().Fail("SometyperService.Op", os.NewError("some failure"))
+}
+
+type StreamerService struct {
+
+}
+
+func (r *StreamerService) RemotizeOpen(args, reply *StreamData) os.Error {
+	return ServeOpen(args, reply)
+}
+
+func (r *StreamerService) RemotizePull(args, reply *StreamData) os.Error {
+	return ServePull(args, reply)
+}
+
+func (r *StreamerService) RemotizePush(args, reply *StreamData) os.Error {
+	return ServePush(args, reply)
+}
+
+func streamerClient(t *testing.T) *rpc.Client {
+	srv := rpc.NewServer()
+	if e := srv.Register(new(StreamerService)); e != nil {
+		t.Fatal(e)
+	}
+	sconn, cconn := net.Pipe()
+	go srv.ServeConn(sconn)
+	return rpc.NewClient(cconn)
+}
+
+func checkStreamed(t *testing.T, ch chan int, n int) {
+	i := 0
+	for v := range ch {
+		if v != i {
+			t.Fatalf("Expected %d but got %d!", i, v)
+		}
+		i++
+	}
+	if i != n {
+		t.Fatalf("Expected %d values but got %d!", n, i)
+	}
+}
+
+func TestStreamDown(t *testing.T) {
+	cli := streamerClient(t)
+	defer cli.Close()
+	src := make(chan int)
+	s := NewStream()
+	go SendChan(s, src)
+	go func() {
+		for i := 0; i < 10; i++ {
+			src <- i
+		}
+		close(src)
+	}()
+	dst := make(chan int)
+	go RecvChan(DialStream(cli, "StreamerService", s.Id()), dst)
+	checkStreamed(t, dst, 10)
+}
+
+func TestStreamIds(t *testing.T) {
+	a, b := NewStream(), NewStream()
+	defer dropStream(a.Id())
+	defer dropStream(b.Id())
+	if a.Id() == 0 || b.Id() == 0 || a.Id() == b.Id() || b.Id() == a.Id()+1 {
+		t.Fatalf("Expected distinct random stream ids but got %v and %v!", a.Id(), b.Id())
+	}
+}
+
+func TestStreamUp(t *testing.T) {
+	cli := streamerClient(t)
+	defer cli.Close()
+	rs, e := OpenStream(cli, "StreamerService")
+	if e != nil {
+		t.Fatal(e)
+	}
+	s, e := FindStream(rs.Id)
+	if e != nil {
+		t.Fatal(e)
+	}
+	src := make(chan int)
+	go SendChan(rs, src)
+	go func() {
+		for i := 0; i < 10; i++ {
+			src <- i
+		}
+		close(src)
+	}()
+	dst := make(chan int)
+	go RecvChan(s, dst)
+	checkStreamed(t, dst, 10)
+}
+
+func TestStreamIdleUp(t *testing.T) {
+	cli := streamerClient(t)
+	defer cli.Close()
+	rs, e := OpenStream(cli, "StreamerService")
+	if e != nil {
+		t.Fatal(e)
+	}
+	s, e := FindStream(rs.Id)
+	if e != nil {
+		t.Fatal(e)
+	}
+	dst := make(chan int)
+	go RecvChan(s, dst)
+	lease := StreamLease
+	StreamLease = 1e6
+	defer func() {
+		StreamLease = lease
+	}()
+	time.Sleep(10e6)
+	reapIdle(time.Nanoseconds())
+	if _, e := FindStream(rs.Id); e != nil {
+		t.Fatal("Expected the idle upstream stream not to be reaped!")
+	}
+	src := make(chan int)
+	go SendChan(rs, src)
+	go func() {
+		for i := 0; i < 10; i++ {
+			src <- i
+		}
+		close(src)
+	}()
+	checkStreamed(t, dst, 10)
+}
+
+type Echoer struct {
+
+}
+
+func (e *Echoer) Echo(args *string, reply *string) os.Error {
+	*reply = *args
+	return nil
+}
+
+type JoinArgs struct {
+	Head string `json:"head"`
+	Tail string `json:"tail"`
+}
+
+func (e *Echoer) Join(args *JoinArgs, reply *string) os.Error {
+	*reply = args.Head + args.Tail
+	return nil
 }
 
+func (e *Echoer) RemotizeBatch(args *BatchArgs, reply *BatchReply) os.Error {
+	return ServeBatch(e, args, reply)
+}
+
+func TestCallback(t *testing.T) {
+	cli := streamerClient(t)
+	defer cli.Close()
+	if _, e := ServeCallback(cli, "StreamerService", nil); e == nil {
+		t.Fatal("Expected an error serving a nil callback!")
+	}
+	id, e := ServeCallback(cli, "StreamerService", new(Echoer))
+	if e != nil {
+		t.Fatal(e)
+	}
+	cb, e := DialCallback(id)
+	if e != nil {
+		t.Fatal(e)
+	}
+	hello, reply := "hello", ""
+	if e := cb.Call("Echoer.Echo", &hello, &reply); e != nil {
+		t.Fatal(e)
+	}
+	if reply != hello {
+		t.Fatalf("Expected '%s' but got '%s'!", hello, reply)
+	}
+	cb.Close() // as the service does once the call returns
+	for i := 0; i < 100; i++ {
+		if _, e := FindStream(id); e != nil {
+			return
+		}
+		time.Sleep(10e6)
+	}
+	t.Fatal("Expected the callback stream to be gone once closed!")
+}
+
+func TestObject(t *testing.T) {
+	cli := streamerClient(t)
+	defer cli.Close()
+	if _, e := ServeObject(nil); e == nil {
+		t.Fatal("Expected an error serving a nil object!")
+	}
+	id, e := ServeObject(new(Echoer))
+	if e != nil {
+		t.Fatal(e)
+	}
+	ocli := rpc.NewClient(DialStream(cli, "StreamerService", id))
+	hello, reply := "hello", ""
+	if e := ocli.Call("Echoer.Echo", &hello, &reply); e != nil {
+		t.Fatal(e)
+	}
+	if reply != hello {
+		t.Fatalf("Expected '%s' but got '%s'!", hello, reply)
+	}
+	if e := ocli.Close(); e != nil {
+		t.Fatal(e)
+	}
+	if _, e := FindStream(id); e == nil {
+		t.Fatal("Expected the object to be released!")
+	}
+	if e := Release(new(Echoer)); e == nil {
+		t.Fatal("Expected an error releasing a non remote proxy!")
+	}
+}
+
+func TestTransports(t *testing.T) {
+	for _, tr := range []Transport{Gob, JSON, JSON2} {
+		srv := rpc.NewServer()
+		srv.Register(new(Echoer))
+		c, s := net.Pipe()
+		go ServeConn(tr, srv, s)
+		cli := NewClient(tr, c)
+		hello, reply := "hello", ""
+		if e := cli.Call("Echoer.Echo", &hello, &reply); e != nil {
+			t.Fatalf("Transport %d: %v", tr, e)
+		}
+		if reply != hello {
+			t.Fatalf("Transport %d: expected '%s' but got '%s'!", tr, hello, reply)
+		}
+		if e := cli.Call("Echoer.Missing", &hello, &reply); e == nil {
+			t.Fatalf("Transport %d: expected an error calling a missing method!", tr)
+		}
+		cli.Close()
+	}
+}
+
+func TestJSON2Wire(t *testing.T) {
+	srv := rpc.NewServer()
+	srv.Register(new(Echoer))
+	c, s := net.Pipe()
+	defer c.Close()
+	go ServeConn(JSON2, srv, s)
+	go io.WriteString(c, `{"jsonrpc":"2.0","method":"Echoer.Echo","params":["hi"]}`+
+		`{"jsonrpc":"2.0","method":"Echoer.Echo","params":["hello"],"id":7}`)
+	dec := json.NewDecoder(c)
+	var ok struct {
+		Version string `json:"jsonrpc"`
+		Result  string `json:"result"`
+		Id      int    `json:"id"`
+	}
+	if e := dec.Decode(&ok); e != nil {
+		t.Fatal(e)
+	}
+	if ok.Version != "2.0" || ok.Result != "hello" || ok.Id != 7 {
+		t.Fatalf("Unexpected response %v!", ok)
+	}
+	go io.WriteString(c, `{"jsonrpc":"2.0","method":"Echoer.Join","params":["he","llo"],"id":8}`)
+	if e := dec.Decode(&ok); e != nil {
+		t.Fatal(e)
+	}
+	if ok.Result != "hello" || ok.Id != 8 {
+		t.Fatalf("Unexpected positional response %v!", ok)
+	}
+	go io.WriteString(c, `{"jsonrpc":"2.0","method":"Echoer.Missing","params":["hello"],"id":"x"}`)
+	var failed struct {
+		Error struct {
+			Code int `json:"code"`
+		} `json:"error"`
+		Id string `json:"id"`
+	}
+	if e := dec.Decode(&failed); e != nil {
+		t.Fatal(e)
+	}
+	if failed.Error.Code != json2MethodNotFound || failed.Id != "x" {
+		t.Fatalf("Unexpected response %v!", failed)
+	}
+}
+
+func checkEcho(t *testing.T, c Caller) {
+	hello, reply := "hello", ""
+	if e := c.Call("Echoer.Echo", &hello, &reply); e != nil {
+		t.Fatal(e)
+	}
+	if reply != hello {
+		t.Fatalf("Expected '%s' but got '%s'!", hello, reply)
+	}
+	reply = ""
+	call := <-c.Go("Echoer.Echo", &hello, &reply, nil).Done
+	if call.Error != nil {
+		t.Fatal(call.Error)
+	}
+	if reply != hello {
+		t.Fatalf("Expected '%s' but got '%s'!", hello, reply)
+	}
+	if e := c.Call("Echoer.Missing", &hello, &reply); e == nil {
+		t.Fatal("Expected an error calling a missing method!")
+	}
+}
+
+func TestDirectCaller(t *testing.T) {
+	if _, e := NewDirectCaller(nil); e == nil {
+		t.Fatal("Expected an error calling a nil service!")
+	}
+	c, e := NewDirectCaller(new(Echoer))
+	if e != nil {
+		t.Fatal(e)
+	}
+	checkEcho(t, c)
+	var reply string
+	if e := c.Call("Echoer.Echo", nil, &reply); e == nil {
+		t.Fatal("Expected an error calling with nil args!")
+	}
+	if e := Disconnect(c); e != nil {
+		t.Fatal(e)
+	}
+}
+
+func TestHTTPCaller(t *testing.T) {
+	l, e := net.Listen("tcp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer l.Close()
+	srv := rpc.NewServer()
+	srv.Register(new(Echoer))
+	go http.Serve(l, HTTPHandler(Gob, srv))
+	checkEcho(t, NewHTTPCaller(Gob, "http://"+l.Addr().String()+"/", nil))
+}
+
+func TestLoopback(t *testing.T) {
+	if _, e := NewLoopback(new(Sometyper)); e == nil {
+		t.Fatal("Expected an error serving a non rpc service!")
+	}
+	cli, e := NewLoopback(new(Echoer))
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer cli.Close()
+	checkEcho(t, cli)
+}
+
+func TestBatch(t *testing.T) {
+	cli, e := NewLoopback(new(Echoer))
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer cli.Close()
+	trace := make([]string, 0)
+	o := NewOptions(WithInterceptor(func(call *ClientCall, next func() os.Error) os.Error {
+		trace = append(trace, call.Service+"."+call.Method)
+		return next()
+	}))
+	b := o.NewBatch(cli, "Echoer")
+	words := []string{"one", "two", "three"}
+	for _, word := range words {
+		args, reply := word, ""
+		b.Queue("Echo", &args, &reply, func() ([]interface{}, os.Error) {
+			return []interface{}{reply}, nil
+		})
+	}
+	b.Queue("Missing", new(string), new(string), nil)
+	if b.Len() != len(words)+1 {
+		t.Fatalf("Expected %d queued calls but got %d!", len(words)+1, b.Len())
+	}
+	results, e := b.Send()
+	if e != nil {
+		t.Fatal(e)
+	}
+	if b.Len() != 0 {
+		t.Fatal("Expected the batch to be empty once sent!")
+	}
+	if expected := []string{"Echoer.RemotizeBatch"}; !reflect.DeepEqual(trace, expected) {
+		t.Fatalf("Expected %v but got %v!", expected, trace)
+	}
+	for i, word := range words {
+		if results[i].Error != nil {
+			t.Fatal(results[i].Error)
+		}
+		if results[i].Results[0] != word {
+			t.Fatalf("Expected '%s' but got '%v'!", word, results[i].Results[0])
+		}
+	}
+	if results[len(words)].Error == nil {
+		t.Fatal("Expected an error calling a missing method!")
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	p, e := ParsePolicy(": idempotent timeout=2s, retries=3 backoff=1.5ms")
+	if e != nil {
+		t.Fatal(e)
+	}
+	expected := Policy{Timeout: 2e9, Retries: 3, Backoff: 15e5, Idempotent: true}
+	if !reflect.DeepEqual(p, expected) {
+		t.Fatalf("Expected %v but got %v!", expected, p)
+	}
+	for _, bad := range []string{"timeout=2", "timeout=2y", "retries=x", "fast"} {
+		if _, e := ParsePolicy(bad); e == nil {
+			t.Fatalf("Expected an error parsing '%s'!", bad)
+		}
+	}
+}
+
+// flakyCaller echoes after failing some calls first, or hangs
+type flakyCaller struct {
+	fails int
+	calls int
+	hang  bool
+}
+
+func (f *flakyCaller) Call(serviceMethod string, args interface{}, reply interface{}) os.Error {
+	f.calls++
+	if f.calls <= f.fails {
+		return os.NewError("connection lost")
+	}
+	*reply.(*string) = *args.(*string)
+	return nil
+}
+
+func (f *flakyCaller) Go(serviceMethod string, args interface{}, reply interface{},
+done chan *rpc.Call) *rpc.Call {
+	call := &rpc.Call{ServiceMethod: serviceMethod, Args: args, Reply: reply, Done: done}
+	if !f.hang {
+		call.Error = f.Call(serviceMethod, args, reply)
+		done <- call
+	}
+	return call
+}
+
+func TestPolicy(t *testing.T) {
+	hello, reply := "hello", ""
+	o := NewOptions(WithPolicy("Get", Policy{Retries: 2, Idempotent: true}),
+		WithPolicy("", Policy{Retries: 2}))
+	if e := o.Call(&flakyCaller{fails: 2}, "Echoer.Get", &hello, &reply); e != nil {
+		t.Fatal(e)
+	}
+	if reply != hello {
+		t.Fatalf("Expected '%s' but got '%s'!", hello, reply)
+	}
+	if e := o.Call(&flakyCaller{fails: 3}, "Echoer.Get", &hello, &reply); e == nil {
+		t.Fatal("Expected an error after too many retries!")
+	}
+	if e := o.Call(&flakyCaller{fails: 1}, "Echoer.Set", &hello, &reply); e == nil {
+		t.Fatal("Expected an error retrying a non idempotent method!")
+	}
+	o = NewOptions(WithPolicy("Get", Policy{Timeout: 1e6}))
+	reply = ""
+	if e := o.Call(&flakyCaller{hang: true}, "Echoer.Get", &hello, &reply); e != ErrTimeout {
+		t.Fatalf("Expected a timeout but got %v!", e)
+	}
+	if e := o.Call(&flakyCaller{}, "Echoer.Get", &hello, &reply); e != nil || reply != hello {
+		t.Fatalf("Expected '%s' but got '%s' (%v)!", hello, reply, e)
+	}
+	if e := o.Go(&flakyCaller{hang: true}, "Echoer.Get", &hello, &reply).Wait(); e != ErrTimeout {
+		t.Fatalf("Expected an asynchronous timeout but got %v!", e)
+	}
+	b := o.NewBatch(&flakyCaller{hang: true}, "Echoer")
+	b.Queue("Get", &hello, &reply, nil)
+	if _, e := b.Send(); e != ErrTimeout {
+		t.Fatalf("Expected a batch timeout but got %v!", e)
+	}
+}
+
+// stuckCaller never answers a call until released
+type stuckCaller chan bool
+
+func (s stuckCaller) Call(serviceMethod string, args interface{}, reply interface{}) os.Error {
+	<-s
+	return nil
+}
+
+func (s stuckCaller) Go(serviceMethod string, args interface{}, reply interface{},
+done chan *rpc.Call) *rpc.Call {
+	panic("not used")
+}
+
+func TestContext(t *testing.T) {
+	ctx, cancel := WithTimeout(Background(), 1e6)
+	defer cancel()
+	<-ctx.Done()
+	if ctx.Err() != ErrDeadline {
+		t.Fatalf("Expected the deadline to pass but got %v!", ctx.Err())
+	}
+	if left := Remaining(ctx); left != 1 {
+		t.Fatalf("Expected a nanosecond left but got %v!", left)
+	}
+	parent, cancelParent := WithCancel(Background())
+	child, cancelChild := WithTimeout(parent, 60e9)
+	defer cancelChild()
+	if left := Remaining(child); left <= 0 || left > 60e9 {
+		t.Fatalf("Unexpected time left %v!", left)
+	}
+	cancelParent()
+	<-child.Done()
+	if child.Err() != ErrCanceled {
+		t.Fatalf("Expected the child cancelled but got %v!", child.Err())
+	}
+	hello, reply := "hello", ""
+	o := NewOptions()
+	if e := o.CallContext(child, 0, &flakyCaller{}, "Echoer.Get", &hello, &reply); e != ErrCanceled {
+		t.Fatalf("Expected no call on a cancelled context but got %v!", e)
+	}
+	if e := o.CallContext(Background(), 0, &flakyCaller{}, "Echoer.Get", &hello, &reply); e != nil ||
+		reply != hello {
+		t.Fatalf("Expected '%s' but got '%s' (%v)!", hello, reply, e)
+	}
+	stuck := make(stuckCaller)
+	defer close(stuck)
+	ctx, cancel = WithTimeout(Background(), 1e6)
+	defer cancel()
+	if e := o.CallContext(ctx, 0, stuck, "Echoer.Get", &hello, &reply); e != ErrDeadline {
+		t.Fatalf("Expected the call to expire but got %v!", e)
+	}
+	server, cancel := ServerContext(0, 0)
+	if _, ok := server.Deadline(); ok {
+		t.Fatal("Expected no deadline on the server context!")
+	}
+	cancel()
+	if server.Err() != ErrCanceled {
+		t.Fatalf("Expected the server context cancelled but got %v!", server.Err())
+	}
+}
+
+// Waiter serves calls waiting for their Context, telling why it expired
+type Waiter struct {
+	expired chan os.Error
+}
+
+type WaitArgs struct {
+	Deadline int64
+	Call     uint64
+}
+
+func (w *Waiter) Wait(args *WaitArgs, reply *string) os.Error {
+	ctx, cancel := ServerContext(args.Deadline, args.Call)
+	defer cancel()
+	<-ctx.Done()
+	w.expired <- ctx.Err()
+	return ctx.Err()
+}
+
+func (w *Waiter) RemotizeCancel(args, reply *CancelData) os.Error {
+	return ServeCancel(args, reply)
+}
+
+func TestCancel(t *testing.T) {
+	w := &Waiter{make(chan os.Error, 1)}
+	cli, e := NewLoopback(w)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer cli.Close()
+	ctx, cancel := WithCancel(Background())
+	go func() {
+		time.Sleep(10e6)
+		cancel()
+	}()
+	args, reply := &WaitArgs{Remaining(ctx), NewCallId()}, ""
+	o := NewOptions()
+	if e := o.CallContext(ctx, args.Call, cli, "Waiter.Wait", args, &reply); e != ErrCanceled {
+		t.Fatalf("Expected the call cancelled but got %v!", e)
+	}
+	select {
+	case e := <-w.expired:
+		if e != ErrCanceled {
+			t.Fatalf("Expected the server context cancelled but got %v!", e)
+		}
+	case <-time.After(5e9):
+		t.Fatal("Expected the server to be told about the cancellation!")
+	}
+}
+
+func TestServerInterceptor(t *testing.T) {
+	trace := make([]string, 0)
+	AddServerInterceptor(func(call *ServerCall, next func() os.Error) os.Error {
+		if call.Service == "InterceptedService" {
+			trace = append(trace, "outer:"+call.Method)
+		}
+		return next()
+	})
+	AddServerInterceptor(func(call *ServerCall, next func() os.Error) os.Error {
+		if call.Service != "InterceptedService" {
+			return next()
+		}
+		if call.Method == "Forbidden" {
+			return os.NewError("forbidden")
+		}
+		e := next()
+		trace = append(trace, "inner:"+*call.Reply.(*string))
+		return e
+	})
+	hello, reply := "hello", ""
+	e := ServeCall("InterceptedService", "Echo", &hello, &reply, func() os.Error {
+		reply = hello
+		return nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	expected := []string{"outer:Echo", "inner:hello"}
+	if !reflect.DeepEqual(trace, expected) {
+		t.Fatalf("Expected %v but got %v!", expected, trace)
+	}
+	e = ServeCall("InterceptedService", "Forbidden", &hello, &reply, func() os.Error {
+		t.Fatal("Expected the call to be refused!")
+		return nil
+	})
+	if e == nil {
+		t.Fatal("Expected an error on a refused call!")
+	}
+}
+
+func TestClientInterceptor(t *testing.T) {
+	trace := make([]string, 0)
+	faults := 1
+	o := NewOptions(WithPolicy("Get", Policy{Retries: 1, Idempotent: true}),
+		WithInterceptor(func(call *ClientCall, next func() os.Error) os.Error {
+			trace = append(trace, call.Service+"."+call.Method)
+			return next()
+		}),
+		WithInterceptor(func(call *ClientCall, next func() os.Error) os.Error {
+			if faults > 0 {
+				faults--
+				return os.NewError("injected fault")
+			}
+			e := next()
+			trace = append(trace, *call.Reply.(*string))
+			return e
+		}))
+	hello, reply := "hello", ""
+	if e := o.Call(&flakyCaller{}, "Echoer.Get", &hello, &reply); e != nil {
+		t.Fatal(e)
+	}
+	expected := []string{"Echoer.Get", "Echoer.Get", "hello"}
+	if !reflect.DeepEqual(trace, expected) {
+		t.Fatalf("Expected %v but got %v!", expected, trace)
+	}
+	trace, reply = trace[:0], ""
+	if e := o.Go(&flakyCaller{}, "Echoer.Put", &hello, &reply).Wait(); e != nil {
+		t.Fatal(e)
+	}
+	expected = []string{"Echoer.Put", "hello"}
+	if !reflect.DeepEqual(trace, expected) {
+		t.Fatalf("Expected %v but got %v!", expected, trace)
+	}
+}
+
+type Panicker struct {
+
+}
+
+func (p *Panicker) Panic(args *string, reply *string) os.Error {
+	return ServeCall("Panicker", "Panic", args, reply, func() os.Error {
+		panic(*args)
+	})
+}
+
+func TestPanic(t *testing.T) {
+	cli, e := NewLoopback(new(Panicker))
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer cli.Close()
+	boom, reply := "boom", ""
+	e = NewOptions().Call(cli, "Panicker.Panic", &boom, &reply)
+	p, ok := e.(*PanicError)
+	if !ok {
+		t.Fatalf("Expected a PanicError but got %v!", e)
+	}
+	if p.Method != "Panicker.Panic" || p.Value != boom || p.Stack != "" {
+		t.Fatalf("Unexpected panic %#v!", p)
+	}
+	PanicStacks = true
+	defer func() {
+		PanicStacks = false
+		if r := recover(); r == nil {
+			t.Fatal("Expected a re-panic!")
+		} else if p, ok := r.(*PanicError); !ok || p.Stack == "" {
+			t.Fatalf("Expected a re-panicked PanicError with its stack but got %v!", r)
+		}
+	}()
+	NewOptions(WithRePanic()).Call(cli, "Panicker.Panic", &boom, &reply)
+}
+
+var errGone = os.NewError("gone")
+
+func init() {
+	RegisterError("remotize_test.errGone", errGone)
+}
+
+type Remover struct {
+
+}
+
+type RemoverReply struct {
+	Arg0 os.Error
+}
+
+func (r *Remover) Remove(args *string, reply *RemoverReply) os.Error {
+	e := errGone
+	if *args != "" {
+		e = os.Remove(*args)
+	}
+	reply.Arg0 = ToRemoteError(e)
+	return nil
+}
+
+func TestRemoteError(t *testing.T) {
+	cli, e := NewLoopback(new(Remover))
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer cli.Close()
+	missing := "/nonexistent/remotize/file"
+	var reply RemoverReply
+	if e := cli.Call("Remover.Remove", &missing, &reply); e != nil {
+		t.Fatal(e)
+	}
+	e = FromRemoteError(reply.Arg0)
+	local := os.Remove(missing)
+	if e == nil || e.String() != local.String() {
+		t.Fatalf("Expected '%v' but got '%v'!", local, e)
+	}
+	if TypeOf(e) != "*os.PathError" || !Is(e, os.ENOENT) || Is(e, errGone) {
+		t.Fatalf("Unexpected error type %s or chain %#v!", TypeOf(e), e)
+	}
+	gone := ""
+	if e := cli.Call("Remover.Remove", &gone, &reply); e != nil {
+		t.Fatal(e)
+	}
+	if e := FromRemoteError(reply.Arg0); e != errGone {
+		t.Fatalf("Expected the sentinel '%v' but got '%v'!", errGone, e)
+	}
+}
+
+// QuotaError is a registered error struct
+type QuotaError struct {
+	Limit int
+}
+
+func (q *QuotaError) String() string {
+	return fmt.Sprintf("over quota of %d", q.Limit)
+}
+
+// StoreError wraps the error storing something
+type StoreError struct {
+	Key string
+	Err os.Error
+}
+
+func (s *StoreError) String() string {
+	return "storing " + s.Key + ": " + s.Err.String()
+}
+
+var errQuota = &QuotaError{10}
+
+func TestAs(t *testing.T) {
+	RegisterError("remotize_test.errQuota", errQuota)
+	e := FromRemoteError(ToRemoteError(&StoreError{"k", errQuota})) // as if it travelled
+	var q *QuotaError
+	if !As(e, &q) || q != errQuota {
+		t.Fatalf("Expected to find the quota error within %#v but got %v!", e, q)
+	}
+	var r *RemoteError
+	if !As(e, &r) || r.Type != "*remotize.StoreError" {
+		t.Fatalf("Expected the travelled store error but got %#v!", r)
+	}
+	var s *StoreError
+	if As(e, &s) {
+		t.Fatal("Unexpected store error, it travelled as a RemoteError!")
+	}
+	var local os.Error
+	if !As(&StoreError{"k", errQuota}, &local) || local.String() != "storing k: over quota of 10" {
+		t.Fatalf("Expected the store error itself but got %v!", local)
+	}
+}
+
+type unregistered struct {
+	Value int
+}
+
+func TestRegisterType(t *testing.T) {
+	args := &struct{ Arg0 []interface{} }{[]interface{}{1, "two", unregistered{3}}}
+	if e := checkRegistered(args); e == nil {
+		t.Fatal("Expected an error sending an unregistered type!")
+	}
+	RegisterType(unregistered{})
+	if e := checkRegistered(args); e != nil {
+		t.Fatal(e)
+	}
+}
+
+// anode and bnode are mutually recursive, only anode holds an interface itself
+type anode struct {
+	Next  *bnode
+	Value interface{}
+}
+
+type bnode struct {
+	Back *anode
+}
+
+type unlisted struct {
+	Value int
+}
+
+func TestRecursiveHolds(t *testing.T) {
+	if e := checkRegistered(&anode{}); e != nil {
+		t.Fatal(e)
+	}
+	if e := checkRegistered(&bnode{&anode{Value: unlisted{1}}}); e == nil {
+		t.Fatal("Expected an error sending an unregistered type within a recursive one!")
+	}
+}
@@ -2,34 +2,10 @@
 package dep
 
 import (
-	"gob"
 	"os"
 	"strings"
 )
 
-func init() {
-	// Allow remotization (gob-ability) of my own error...
-	gob.Register(new(myError))
-}
-
-// my error type
-type myError struct {
-	Err string
-}
-
-// Meets the Error interface
-func (e *myError) String() string {
-	return e.Err
-}
-
-// wrapError will wrap an os.Error to be myError and thus, be gob-able = sendable
-func wrapError(e os.Error) os.Error {
-	if e == nil {
-		return nil
-	}
-	return &myError{e.String()}
-}
-
 // Stateless version of a (local) File Service
 type FileService struct {
 
@@ -38,59 +14,59 @@ type FileService struct {
 // Create returns a new File created by a given name
 func (fs *FileService) Create(filename string) os.Error {
 	_, e := os.Create(filename)
-	return wrapError(e)
+	return e
 }
 
 // Mkdir creates a new directory (and all subdirectories in between)
 func (fs *FileService) Mkdir(filename string) os.Error {
-	return wrapError(os.MkdirAll(filename, 0755))
+	return os.MkdirAll(filename, 0755)
 }
 
 // Remove will delete a file by a given name
 func (fs *FileService) Remove(filename string) os.Error {
-	return wrapError(os.Remove(filename))
+	return os.Remove(filename)
 }
 
 // FileInfo returns the fileinfo for a give file name
 func (fs *FileService) FileInfo(filename string) (*os.FileInfo, os.Error) {
 	fi, e := os.Lstat(filename)
-	return fi, wrapError(e)
+	return fi, e
 }
 
 // Rename will rename a directory or file
 func (fs *FileService) Rename(oldname, newname string) os.Error {
-	return wrapError(os.Rename(oldname, newname))
+	return os.Rename(oldname, newname)
 }
 
 // ReadAt will read a filename at a given offset into a given byte array
 func (fs *FileService) ReadAt(filename string, b []byte, off int64) (int, os.Error) {
 	f, e := os.Open(filename)
 	if e != nil {
-		return 0, wrapError(e)
+		return 0, e
 	}
 	n, e := f.ReadAt(b, off)
-	return n, wrapError(e)
+	return n, e
 }
 
 // WriteAt will write the given bytes at a certain offset on filename
 func (fs *FileService) WriteAt(filename string, b []byte, off int64) (int, os.Error) {
 	f, e := os.Open(filename)
 	if e != nil {
-		return 0, wrapError(e)
+		return 0, e
 	}
 	defer gosync(f)
 	n, e := f.WriteAt(b, off)
-	return n, wrapError(e)
+	return n, e
 }
 
 // ReadDir will list the directory contents
 func (fs *FileService) Readdir(filename string, n int) ([]os.FileInfo, os.Error) {
 	f, e := os.Open(filename)
 	if e != nil {
-		return nil, wrapError(e)
+		return nil, e
 	}
 	fis, e := f.Readdir(n)
-	return fis, wrapError(e)
+	return fis, e
 }
 
 func gosync(f *os.File) {
@@ -117,7 +93,7 @@ func (ps *ProcessService) NewProcess(cmd string) (int, os.Error) {
 	name := argv[0]
 	p, e := os.StartProcess(name, argv, &os.ProcAttr{"", nil, nil, nil})
 	if e != nil {
-		return -1, wrapError(e)
+		return -1, e
 	}
 	return p.Pid, nil
 }
@@ -126,18 +102,18 @@ func (ps *ProcessService) NewProcess(cmd string) (int, os.Error) {
 func (ps *ProcessService) Kill(pid int) os.Error {
 	p, e := os.FindProcess(pid)
 	if e != nil {
-		return wrapError(e)
+		return e
 	}
-	return wrapError(p.Kill())
+	return p.Kill()
 }
 
 // Wait will wait a Process
 func (ps *ProcessService) Wait(pid int, options int) (*os.Waitmsg, os.Error) {
 	p, e := os.FindProcess(pid)
 	if e != nil {
-		return nil, wrapError(e)
+		return nil, e
 	}
 	r, e := p.Wait(options)
-	return r, wrapError(e)
+	return r, e
 }
 
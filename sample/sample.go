@@ -3,11 +3,8 @@ package sample
 import (
 	"github.com/josvazg/remotize"
 	"sample/dep"
-	"http"
 	"math"
-	"net"
 	"os"
-	"rpc"
 	"strconv"
 	"sync"
 )
@@ -40,6 +37,7 @@ func NewURLStore() *URLStore {
 }
 
 // Get a url from the store
+// (remotize idempotent timeout=2s retries=2 backoff=100ms)
 func (s *URLStore) Get(shorturl string) string {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -58,30 +56,6 @@ func (s *URLStore) Set(shorturl, url string) bool {
 	return true
 }
 
-// startStorerServer starts a RPC URLStorer server given an implementation
-func startStorerServer(us URLStorer) (string, os.Error) {
-	// You can also search the service by passing the impleemntation to remotize...
-	r := remotize.NewService(us)
-	rpc.Register(r)
-	rpc.HandleHTTP()
-	addr := ":12345"
-	l, e := net.Listen("tcp", addr)
-	if e != nil {
-		return "", e
-	}
-	go http.Serve(l, nil)
-	return "localhost" + addr, nil
-}
-
-// getRemoteStorerRef Gets a local reference to a remote URLStorer Service
-func getRemoteStorerRef(saddr string) (URLStorer, os.Error) {
-	client, e := rpc.DialHTTP("tcp", saddr)
-	if e != nil {
-		return nil, e
-	}
-	return remotize.NewRemote(client, new(URLStorer)).(URLStorer), nil
-}
-
 //
 //
 //     CALCER SAMPLE SECTION: Remotization of an INTERFACE in the SAME package (from source code)
@@ -145,89 +119,3 @@ func (c *Calc) Randomize() {
 // Randomize seed does nothing either, it's just a sample of rpc WITH 1 arg a 0 results
 func (c *Calc) RandomizeSeed(seed float64) {
 }
-
-// startCalcerServer starts a RPC Calcer server using Calc as the implementation
-func startCalcerServer() (string, os.Error) {
-	// You can access the remotized code directly, it should be created by now...
-	r := NewCalcerService(new(Calc))
-	rpc.Register(r)
-	rpc.HandleHTTP()
-	addr := ":1234"
-	l, e := net.Listen("tcp", addr)
-	if e != nil {
-		return "", e
-	}
-	go http.Serve(l, nil)
-	return "localhost" + addr, nil
-}
-
-// getRemoteCalcerRef returns a local reference to a remote Calcer RPC service
-func getRemoteCalcerRef(saddr string) (Calcer, os.Error) {
-	client, e := rpc.DialHTTP("tcp", saddr)
-	if e != nil {
-		return nil, e
-	}
-	return NewRemoteCalcer(client), nil
-}
-
-//
-//
-//     FILER SAMPLE SECTION: Remotization of a TYPE from OTHER package (from reflection type)
-//
-//
-
-// startFilerServer starts a RPC File server using dep.FileService as the implementation
-func startFilerServer() (string, os.Error) {
-	// You can access the remotized code directly, it should be created by now...
-	r := NewFileServicerService(new(dep.FileService))
-	rpc.Register(r)
-	rpc.HandleHTTP()
-	addr := ":23456"
-	l, e := net.Listen("tcp", addr)
-	if e != nil {
-		return "", e
-	}
-	go http.Serve(l, nil)
-	return "localhost" + addr, nil
-}
-
-// getRemoteFileServicerRef returns a local reference to a remote dep.FileService RPC service
-func getRemoteFileServicerRef(saddr string) (FileServicer, os.Error) {
-	client, e := rpc.DialHTTP("tcp", saddr)
-	if e != nil {
-		return nil, e
-	}
-	return NewRemoteFileServicer(client), nil
-}
-
-//
-//
-//     PROCESS SERVICER SECTION: Remotization of an INTERFACE from OTHER package
-//
-//
-
-// startProcessServer starts a RPC Process server using dep.ProcessService as the implementation
-func startProcessServer() (string, os.Error) {
-	// You can access the remotized code directly, it should be created by now...
-	r := NewProcessServicerService(new(dep.ProcessService))
-	rpc.Register(r)
-	rpc.HandleHTTP()
-	addr := ":34567"
-	l, e := net.Listen("tcp", addr)
-	if e != nil {
-		return "", e
-	}
-	go http.Serve(l, nil)
-	return "localhost" + addr, nil
-}
-
-// getRemoteProcessServicerRef returns a local reference to a remote dep.ProcessService RPC service
-func getRemoteProcessServicerRef(saddr string) (dep.ProcessServicer, os.Error) {
-	client, e := rpc.DialHTTP("tcp", saddr)
-	if e != nil {
-		return nil, e
-	}
-	return NewRemoteProcessServicer(client), nil
-}
-
-
@@ -1,6 +1,7 @@
 package sample
 
 import (
+	"github.com/josvazg/remotize"
 	"sample/dep"
 	"os"
 	test "testing"
@@ -8,7 +9,7 @@ import (
 
 func dieOnError(t *test.T, e os.Error) {
 	if e != nil {
-		t.Fatalf("listen error: %v", e)
+		t.Fatalf("loopback error: %v", e)
 	}
 }
 
@@ -46,11 +47,11 @@ var calcTests = []struct {
 }
 
 func TestRemotizedCalc(t *test.T) {
-	serveraddr, e := startCalcerServer()
+	cli, e := remotize.NewLoopback(NewCalcerService(new(Calc)))
 	dieOnError(t, e)
+	defer cli.Close()
 	calc := new(Calc)
-	rcalc, e := getRemoteCalcerRef(serveraddr)
-	dieOnError(t, e)
+	rcalc := NewRemoteCalcer(cli)
 	for _, ct := range calcTests {
 		switch ct.op {
 		case Add:
@@ -83,6 +84,63 @@ func TestRemotizedCalc(t *test.T) {
 	}
 }
 
+func TestRemotizedCalcAsync(t *test.T) {
+	cli, e := remotize.NewLoopback(NewCalcerService(new(Calc)))
+	dieOnError(t, e)
+	defer cli.Close()
+	calc := new(Calc)
+	rcalc := NewRemoteCalcer(cli)
+	adds := make([]*CalcerAddFuture, len(calcTests))
+	for i, ct := range calcTests {
+		adds[i] = rcalc.AddAsync(float64(i), float64(ct.op))
+	}
+	for i, ct := range calcTests {
+		check(t, ct, calc.Add(float64(i), float64(ct.op)) == adds[i].Wait())
+		check(t, ct, adds[i].Done())
+	}
+	add, radd := 1.5, 1.5
+	calc.AddTo(&add, 2)
+	rcalc.AddToAsync(&radd, 2).Wait()
+	check(t, "AddToAsync", add == radd)
+	_, le := calc.Divide(1, 0)
+	_, e = rcalc.DivideAsync(1, 0).Wait()
+	check(t, "DivideAsync", e != nil && e.String() == le.String())
+}
+
+func TestRemotizedCalcBatch(t *test.T) {
+	cli, e := remotize.NewLoopback(NewCalcerService(new(Calc)))
+	dieOnError(t, e)
+	defer cli.Close()
+	calc := new(Calc)
+	rcalc := NewRemoteCalcer(cli)
+	add, radd := 1.5, 1.5
+	calc.AddTo(&add, 2)
+	results, e := rcalc.Batch().Add(1, 2).Multiply(3, 4).AddTo(&radd, 2).Divide(1, 0).Send()
+	dieOnError(t, e)
+	check(t, "Batch", len(results) == 4)
+	check(t, "Add", results[0].Error == nil && results[0].Results[0] == calc.Add(1, 2))
+	check(t, "Multiply", results[1].Error == nil && results[1].Results[0] == calc.Multiply(3, 4))
+	check(t, "AddTo", results[2].Error == nil && add == radd)
+	_, le := calc.Divide(1, 0)
+	check(t, "Divide", results[3].Error != nil && results[3].Error.String() == le.String())
+}
+
+func TestRemotizedCalcIntercepted(t *test.T) {
+	cli, e := remotize.NewLoopback(NewCalcerService(new(Calc)))
+	dieOnError(t, e)
+	defer cli.Close()
+	seen := make([]string, 0)
+	rcalc := NewRemoteCalcer(cli, remotize.WithInterceptor(
+		func(call *remotize.ClientCall, next func() os.Error) os.Error {
+			seen = append(seen, call.Method)
+			return next()
+		}))
+	check(t, "AddAsync", rcalc.AddAsync(1, 2).Wait() == 3)
+	_, e = rcalc.Batch().Add(1, 2).Multiply(3, 4).Send()
+	dieOnError(t, e)
+	check(t, "Intercepted", len(seen) == 2 && seen[0] == "Add" && seen[1] == "RemotizeBatch")
+}
+
 var ustorerTests = []struct {
 	shorturl, url string
 }{
@@ -93,11 +151,12 @@ var ustorerTests = []struct {
 }
 
 func TestRemotizedURLStorer(t *test.T) {
-	serveraddr, e := startStorerServer(NewURLStore())
+	cli, e := remotize.NewLoopback(remotize.NewService(NewURLStore()))
 	dieOnError(t, e)
+	defer cli.Close()
 	us := NewURLStore()
-	rus, e := getRemoteStorerRef(serveraddr)
-	dieOnError(t, e)
+	var rus URLStorer
+	dieOnError(t, remotize.RemoteOf(cli, &rus))
 	for _, tu := range ustorerTests {
 		us.Set(tu.shorturl, tu.url)
 		rus.Set(tu.shorturl, tu.url)
@@ -141,11 +200,11 @@ func errOrNil(a interface{}, b interface{}) bool {
 func TestRemotizedFiler(t *test.T) {
 	lprefix := "local/"
 	rprefix := "remote/"
-	serveraddr, e := startFilerServer()
+	cli, e := remotize.NewLoopback(NewFileServicerService(new(dep.FileService)))
 	dieOnError(t, e)
+	defer cli.Close()
 	fs := new(dep.FileService)
-	rfs, e := getRemoteFileServicerRef(serveraddr)
-	dieOnError(t, e)
+	rfs := NewRemoteFileServicer(cli)
 	for _, ft := range fileTests {
 		switch ft.op {
 		case Create:
@@ -213,11 +272,11 @@ var procTests = []struct {
 }
 
 func TestRemotizedProcesser(t *test.T) {
-	serveraddr, e := startProcessServer()
+	cli, e := remotize.NewLoopback(NewProcessServicerService(new(dep.ProcessService)))
 	dieOnError(t, e)
+	defer cli.Close()
 	ps := new(dep.ProcessService)
-	rps, e := getRemoteProcessServicerRef(serveraddr)
-	dieOnError(t, e)
+	rps := NewRemoteProcessServicer(cli)
 	lastPid := -1
 	lastRPid := -1
 	for _, pt := range procTests {
@@ -0,0 +1,402 @@
+// Copyright 2011 Jose Luis Vázquez González josvazg@gmail.com
+// Use of this source code is governed by a BSD-style
+
+package remotize
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"gob"
+	"io"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// How many chunks of data can wait on a stream before the writer blocks
+const streamBuffer = 4
+
+// PullTimeout is how long, in nanoseconds, a client pulling data from a
+// stream waits before getting an empty reply and pulling again.
+var PullTimeout int64 = 30e9
+
+// StreamLease is how long, in nanoseconds, a stream lives without its client
+// pulling or pushing again, unless the client is just pushing to it. After that
+// the client is considered gone (its connection dropped) and the stream is reclaimed.
+var StreamLease int64 = 120e9
+
+// ErrStreamGone is returned when writing to a stream whose client is gone.
+var ErrStreamGone = os.NewError("remotize: stream client is gone")
+
+// StreamData is the message exchanged by the rpc calls carrying a Stream.
+//
+// Users DON'T need to care about this, as it is used by the autogenerated code.
+type StreamData struct {
+	Id   uint64
+	Data []byte
+	EOF  bool
+}
+
+// Stream is the service end of a byte pipe between a service and a remote
+// client, carried over plain rpc calls to the service's RemotizeOpen,
+// RemotizePull and RemotizePush methods.
+//
+// Once the client closes its end or is gone, the stream is gone as well.
+type Stream struct {
+	id       uint64
+	in       chan []byte
+	out      chan []byte
+	gone     chan bool
+	pending  []byte
+	leaving  sync.Once
+	inLock   sync.Mutex // guards sending on and closing in
+	outLock  sync.Mutex // guards sending on and closing out
+	closed   bool
+	pulled   bool  // guarded by streamLock
+	pushed   bool  // guarded by streamLock
+	lastSeen int64 // guarded by streamLock
+}
+
+// Streams being served
+var streams = make(map[uint64]*Stream)
+
+// Stream table's lock
+var streamLock sync.Mutex
+
+// Makes sure there is one reaper
+var reaping sync.Once
+
+// NewStream returns a new Stream ready to be reached by a remote client.
+func NewStream() *Stream {
+	reaping.Do(func() {
+		go reapStreams()
+	})
+	streamLock.Lock()
+	defer streamLock.Unlock()
+	s := &Stream{id: newStreamId(),
+		in:       make(chan []byte, streamBuffer),
+		out:      make(chan []byte, streamBuffer),
+		gone:     make(chan bool),
+		lastSeen: time.Nanoseconds()}
+	streams[s.id] = s
+	return s
+}
+
+// newStreamId returns a random unused stream id, so that clients can't guess the ids of
+// the streams of others. Zero is never used, it stands for no stream (a nil channel).
+func newStreamId() uint64 {
+	for {
+		id := randomId()
+		if _, used := streams[id]; !used {
+			return id
+		}
+	}
+	panic("unreachable")
+}
+
+// randomId returns a random id other than zero.
+func randomId() uint64 {
+	var b [8]byte
+	for {
+		if _, e := io.ReadFull(rand.Reader, b[:]); e != nil {
+			panic("remotize: can't get random ids: " + e.String())
+		}
+		if id := binary.BigEndian.Uint64(b[:]); id != 0 {
+			return id
+		}
+	}
+	panic("unreachable")
+}
+
+// FindStream returns the Stream for the given id.
+func FindStream(id uint64) (*Stream, os.Error) {
+	streamLock.Lock()
+	defer streamLock.Unlock()
+	s := streams[id]
+	if s == nil {
+		return nil, os.NewError(fmt.Sprintf("remotize: unknown stream %d", id))
+	}
+	return s, nil
+}
+
+// seen renews the lease of stream id, pulled from or pushed to, and returns it.
+func seen(id uint64, pulling bool) (*Stream, os.Error) {
+	s, e := FindStream(id)
+	if e != nil {
+		return nil, e
+	}
+	streamLock.Lock()
+	defer streamLock.Unlock()
+	s.lastSeen = time.Nanoseconds()
+	s.pulled = s.pulled || pulling
+	s.pushed = s.pushed || !pulling
+	return s, nil
+}
+
+// dropStream forgets about stream id.
+func dropStream(id uint64) {
+	streamLock.Lock()
+	defer streamLock.Unlock()
+	streams[id] = nil, false
+}
+
+// reapStreams periodically forgets the streams whose clients are gone.
+func reapStreams() {
+	for {
+		time.Sleep(StreamLease / 2)
+		reapIdle(time.Nanoseconds())
+	}
+}
+
+// reapIdle forgets the streams whose lease expired by now: those pulled from, and
+// those never used at all. Their writers get ErrStreamGone.
+//
+// Streams only pushed to are never reaped, as their clients may just be idle.
+func reapIdle(now int64) {
+	streamLock.Lock()
+	defer streamLock.Unlock()
+	for id, s := range streams {
+		if (s.pulled || !s.pushed) && now-s.lastSeen > StreamLease {
+			streams[id] = nil, false
+			s.leave()
+		}
+	}
+}
+
+// pushing marks s as pushed to by its client, even before the first value arrives.
+func (s *Stream) pushing() {
+	streamLock.Lock()
+	defer streamLock.Unlock()
+	s.pushed = true
+}
+
+// leave marks the stream's client as gone.
+func (s *Stream) leave() {
+	s.leaving.Do(func() {
+		close(s.gone)
+	})
+}
+
+// Id returns the stream identifier the client uses to reach s.
+func (s *Stream) Id() uint64 {
+	return s.id
+}
+
+// Read reads data sent by the client, it returns os.EOF once the client
+// closed its end or is gone.
+func (s *Stream) Read(b []byte) (int, os.Error) {
+	if len(s.pending) == 0 {
+		var data []byte
+		var ok bool
+		select {
+		case data, ok = <-s.in:
+		case <-s.gone:
+			select { // data sent before leaving is still read
+			case data, ok = <-s.in:
+			default:
+			}
+		}
+		if !ok {
+			return 0, os.EOF
+		}
+		s.pending = data
+	}
+	n := copy(b, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+// Write sends data to the client, blocking while the client doesn't pull
+// what was written before.
+func (s *Stream) Write(b []byte) (int, os.Error) {
+	data := make([]byte, len(b))
+	copy(data, b)
+	s.outLock.Lock()
+	defer s.outLock.Unlock()
+	if s.closed {
+		return 0, ErrStreamGone
+	}
+	select {
+	case s.out <- data:
+	case <-s.gone:
+		return 0, ErrStreamGone
+	}
+	return len(b), nil
+}
+
+// Close tells the client there is no more data to be read, once it pulls
+// what was written before.
+func (s *Stream) Close() os.Error {
+	s.outLock.Lock()
+	defer s.outLock.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.out)
+	}
+	return nil
+}
+
+// ServeOpen opens a new stream and replies its id.
+//
+// Users DON'T need to care about this, as it is called by the autogenerated code.
+func ServeOpen(args, reply *StreamData) os.Error {
+	reply.Id = NewStream().Id()
+	return nil
+}
+
+// ServePull replies the next chunk of data written to the stream, or EOF.
+// When there is nothing to pull for PullTimeout the reply is empty.
+//
+// Users DON'T need to care about this, as it is called by the autogenerated code.
+func ServePull(args, reply *StreamData) os.Error {
+	s, e := seen(args.Id, true)
+	if e != nil {
+		return e
+	}
+	reply.Id = args.Id
+	select {
+	case data, ok := <-s.out:
+		if !ok { // the service end is done, reading included
+			reply.EOF = true
+			dropStream(args.Id)
+			s.leave()
+		}
+		reply.Data = data
+	case <-s.gone:
+		reply.EOF = true
+	case <-time.After(PullTimeout):
+	}
+	return nil
+}
+
+// ServePush passes a chunk of data from the client to the stream's reader,
+// or closes the stream when the client is done.
+//
+// Users DON'T need to care about this, as it is called by the autogenerated code.
+func ServePush(args, reply *StreamData) os.Error {
+	s, e := seen(args.Id, false)
+	if e != nil {
+		return e
+	}
+	reply.Id = args.Id
+	s.inLock.Lock()
+	defer s.inLock.Unlock()
+	if args.EOF {
+		dropStream(args.Id)
+		close(s.in)
+		s.leave()
+	} else if len(args.Data) > 0 {
+		select {
+		case s.in <- args.Data:
+		case <-s.gone:
+			return ErrStreamGone
+		}
+	}
+	return nil
+}
+
+// RemoteStream is the client end of a Stream.
+type RemoteStream struct {
+	Id      uint64
+	cli     Caller
+	service string
+	pending []byte
+	eof     bool
+}
+
+// OpenStream opens a new stream on the given service.
+func OpenStream(cli Caller, service string) (*RemoteStream, os.Error) {
+	var reply StreamData
+	if e := cli.Call(service+".RemotizeOpen", &StreamData{}, &reply); e != nil {
+		return nil, e
+	}
+	return DialStream(cli, service, reply.Id), nil
+}
+
+// DialStream returns the client end for an already open stream id on the
+// given service.
+func DialStream(cli Caller, service string, id uint64) *RemoteStream {
+	return &RemoteStream{Id: id, cli: cli, service: service}
+}
+
+// Read reads data written on the service end of the stream.
+func (r *RemoteStream) Read(b []byte) (int, os.Error) {
+	for len(r.pending) == 0 {
+		if r.eof {
+			return 0, os.EOF
+		}
+		var reply StreamData
+		e := r.cli.Call(r.service+".RemotizePull", &StreamData{Id: r.Id}, &reply)
+		if e != nil {
+			return 0, e
+		}
+		r.pending, r.eof = reply.Data, reply.EOF
+	}
+	n := copy(b, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// Write sends data to the service end of the stream.
+func (r *RemoteStream) Write(b []byte) (int, os.Error) {
+	var reply StreamData
+	e := r.cli.Call(r.service+".RemotizePush", &StreamData{Id: r.Id, Data: b}, &reply)
+	if e != nil {
+		return 0, e
+	}
+	return len(b), nil
+}
+
+// Close tells the service end there is no more data to be read.
+func (r *RemoteStream) Close() os.Error {
+	var reply StreamData
+	return r.cli.Call(r.service+".RemotizePush", &StreamData{Id: r.Id, EOF: true}, &reply)
+}
+
+// SendChan sends all values received from channel ch through w, closing w
+// once ch is closed.
+//
+// Users DON'T need to care about this, as it is called by the autogenerated code.
+func SendChan(w io.WriteCloser, ch interface{}) {
+	defer w.Close()
+	v := reflect.ValueOf(ch)
+	if v.IsNil() {
+		return
+	}
+	enc := gob.NewEncoder(w)
+	for {
+		x, ok := v.Recv()
+		if !ok {
+			return
+		}
+		if e := enc.EncodeValue(x); e != nil {
+			return
+		}
+	}
+}
+
+// RecvChan sends on channel ch all values read from r, closing ch when r
+// has no more. When r is a Stream its client only pushes to it, so it is
+// not reaped while the client stays idle before sending anything.
+//
+// Users DON'T need to care about this, as it is called by the autogenerated code.
+func RecvChan(r io.Reader, ch interface{}) {
+	if s, ok := r.(*Stream); ok {
+		s.pushing()
+	}
+	v := reflect.ValueOf(ch)
+	if v.IsNil() {
+		return
+	}
+	defer v.Close()
+	dec := gob.NewDecoder(r)
+	for {
+		x := reflect.New(v.Type().Elem())
+		if e := dec.DecodeValue(x); e != nil {
+			return
+		}
+		v.Send(x.Elem())
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2011 Jose Luis Vázquez González josvazg@gmail.com
+// Use of this source code is governed by a BSD-style
+
+package tool
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// The annotation setting opting a method (or a whole type) out of the checks
+const noCheckSetting = "nocheck"
+
+// splitNoCheck takes the nocheck setting out of annotation settings, telling whether it was there
+func splitNoCheck(settings string) (string, bool) {
+	kept := make([]string, 0)
+	nocheck := false
+	for _, setting := range strings.Fields(strings.Replace(settings, ",", " ", -1)) {
+		if setting == noCheckSetting {
+			nocheck = true
+		} else {
+			kept = append(kept, setting)
+		}
+	}
+	return strings.Join(kept, " "), nocheck
+}
+
+// noCheck tells whether the method (or the whole Spec) was opted out of the checks
+func (s *Spec) noCheck(method string) bool {
+	for _, m := range []string{method, ""} {
+		if info, ok := s.infos[m]; ok && info.NoCheck {
+			return true
+		}
+	}
+	return false
+}
+
+// check returns the problems found on the method signatures that can't travel through the
+// wire as they are, located as file:line when the source code position is known.
+func (s *Spec) check() []string {
+	problems := make([]string, 0)
+	start := 0
+	if s.t.Kind() != reflect.Interface {
+		start = 1
+	}
+	for _, m := range s.methods() {
+		if !isExported(m.Name) {
+			continue
+		}
+		where := s.name + "." + m.Name
+		if info, ok := s.infos[m.Name]; ok && info.Pos != "" {
+			where = info.Pos + ": " + where
+		}
+		report := func(problem string) {
+			problems = append(problems, fmt.Sprintf("%s: %s", where, problem))
+		}
+		s.checkName(m.Name, start, report) // clashes break the generated code, no opting out
+		if s.noCheck(m.Name) {
+			continue
+		}
+		first := start
+		if takesContext(m.Type, start) { // the Context doesn't travel
+			first++
+		}
+		for j := first; j < m.Type.NumIn(); j++ {
+			s.checkPar(m.Type.In(j), report)
+		}
+		for j := 0; j < m.Type.NumOut(); j++ {
+			s.checkPar(m.Type.Out(j), report)
+		}
+	}
+	return problems
+}
+
+// Method names the generated proxy and batch builder use for their own methods
+var generatedMethods = map[string]string{"Batch": "Remote%s", "Len": "%sBatch",
+	"Send": "%sBatch"}
+
+// checkName reports a method name clashing with the names of the generated code
+func (s *Spec) checkName(name string, start int, report func(string)) {
+	if strings.HasPrefix(name, "Remotize") {
+		report("method names starting with Remotize are reserved for the generated code")
+		return
+	}
+	if s.noClient { // the rest of the clashes are within the client side
+		return
+	}
+	if owner, ok := generatedMethods[name]; ok {
+		report(fmt.Sprintf("clashes with the generated method %s.%s",
+			fmt.Sprintf(owner, s.name), name))
+		return
+	}
+	if !strings.HasSuffix(name, "Async") {
+		return
+	}
+	base := name[:len(name)-len("Async")]
+	for _, m := range s.methods() {
+		if m.Name == base && !streams(m.Type, start) && !takesContext(m.Type, start) {
+			report(fmt.Sprintf("clashes with the generated method Remote%s.%s, "+
+				"the asynchronous variant of %s", s.name, name, base))
+		}
+	}
+}
+
+// checkPar checks a method argument or result of type t, which may be streamed or called back
+func (s *Spec) checkPar(t reflect.Type, report func(string)) {
+	switch {
+	case isError(t) || isRemoteIface(t): // travel as RemoteErrors or remote objects
+	case t.Kind() == reflect.Chan:
+		s.checkType(t.Elem(), report, make(map[reflect.Type]bool))
+	case t.Kind() == reflect.Func:
+		for i := 0; i < t.NumIn(); i++ {
+			s.checkType(t.In(i), report, make(map[reflect.Type]bool))
+		}
+		for i := 0; i < t.NumOut(); i++ {
+			s.checkType(t.Out(i), report, make(map[reflect.Type]bool))
+		}
+	default:
+		s.checkType(t, report, make(map[reflect.Type]bool))
+	}
+}
+
+// checkType checks a type travelling gob encoded, where within holds the types it is within
+func (s *Spec) checkType(t reflect.Type, report func(string), within map[reflect.Type]bool) {
+	if within[t] {
+		report(fmt.Sprintf("recursive type %v", t))
+		return
+	}
+	within[t] = true
+	defer func() {
+		within[t] = false, false
+	}()
+	switch t.Kind() {
+	case reflect.Chan, reflect.Func:
+		report(fmt.Sprintf("%v can only be an argument or result, it can't travel within "+
+			"other values", t))
+	case reflect.UnsafePointer:
+		report(fmt.Sprintf("%v can't travel", t))
+	case reflect.Interface:
+		if !isError(t) && t.NumMethod() > 0 && !s.implemented(t) {
+			report(fmt.Sprintf("no implementation known for interface %v", t))
+		}
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		s.checkType(t.Elem(), report, within)
+	case reflect.Map:
+		s.checkType(t.Key(), report, within)
+		s.checkType(t.Elem(), report, within)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				report(fmt.Sprintf("unexported field %s of %v won't travel", f.Name, t))
+			} else {
+				s.checkType(f.Type, report, within)
+			}
+		}
+	}
+}
+
+// implemented tells whether any of the concrete types known implements interface t
+func (s *Spec) implemented(t reflect.Type) bool {
+	for _, im := range s.impls {
+		if implements(im, t) {
+			return true
+		}
+	}
+	return false
+}
@@ -15,6 +15,7 @@ import (
 	"go/parser"
 	"go/printer"
 	"go/token"
+	"io"
 	"os"
 	"strings"
 )
@@ -52,6 +53,8 @@ type decl struct {
 	isInterface bool
 	Src         *bytes.Buffer
 	imports     map[string]string
+	infos       map[string]*MethodInfo
+	embedded    []string // interfaces embedded from other packages, as pkg.Name
 }
 
 // packname returns the predefined type/interface fullname
@@ -70,6 +73,11 @@ type detected struct {
 	aliases    map[string]string
 	methods    map[string][]*ast.FuncDecl
 	interfaces map[string]*ast.InterfaceType
+	annotated  map[string]map[string]string
+	positions  map[string]map[string]string
+	described  map[string]map[string]*MethodInfo
+	fset       *token.FileSet
+	types      []string
 	decls      []PreSpec
 }
 
@@ -84,10 +92,14 @@ func Detect(files ...string) ([]PreSpec, os.Error) {
 	d.aliases = make(map[string]string)
 	d.methods = make(map[string][]*ast.FuncDecl)
 	d.interfaces = make(map[string]*ast.InterfaceType)
+	d.annotated = make(map[string]map[string]string)
+	d.positions = make(map[string]map[string]string)
+	d.described = make(map[string]map[string]*MethodInfo)
+	d.fset = token.NewFileSet()
 	d.decls = make([]PreSpec, 0)
 	for _, f := range files {
 		//fmt.Println("Parsing ", f, "?") 
-		file, e := parser.ParseFile(token.NewFileSet(), f, nil, parser.ParseComments)
+		file, e := parser.ParseFile(d.fset, f, nil, parser.ParseComments)
 		if e != nil {
 			fmt.Println(e)
 			return nil, e
@@ -95,14 +107,37 @@ func Detect(files ...string) ([]PreSpec, os.Error) {
 		if d.packname == "" {
 			d.packname = file.Name.Name
 		} else if d.packname != file.Name.Name {
-			panic("One package at a time! (can't remotize files from " +
-				d.packname + " and " + file.Name.Name + " at the same time)")
+			return nil, os.NewError("One package at a time! (can't remotize files from " +
+				d.packname + " and " + file.Name.Name + " at the same time, " +
+				"give goremote their directories instead)")
 		}
 		//fmt.Println("Parsing ", f, "...")
 		ast.Walk(d, file)
+		d.recordTypes(file)
 		//ast.Print(token.NewFileSet(), file)
 	}
-	return postProcess(d), nil
+	return postProcess(d)
+}
+
+// Select picks the detected types or interfaces with the given names, failing if any of
+// them was not detected. Types are picked along with their pointer type, and types
+// predefined elsewhere may be named with or without their package.
+func Select(pspecs []PreSpec, names ...string) ([]PreSpec, os.Error) {
+	selected := make([]PreSpec, 0)
+	for _, name := range names {
+		found := false
+		for _, ps := range pspecs {
+			pname := strings.TrimLeft(ps.name(), "*")
+			if pname == name || pname[strings.LastIndex(pname, ".")+1:] == name {
+				selected = append(selected, ps)
+				found = true
+			}
+		}
+		if !found {
+			return nil, os.NewError("Can't find " + name + " among the remotizables detected")
+		}
+	}
+	return selected, nil
 }
 
 // Visit parses the whole source code
@@ -141,6 +176,9 @@ func (d *detected) parseImports(ispec *ast.ImportSpec) {
 
 // parseComment will search for interfaces or types in the source code preceeded 
 // by a comment ending with '(remotize)' and will mark them for remotization
+//
+// The annotation may carry the default call policy for all methods, like in
+// '(remotize timeout=5s)'
 func (d *detected) parseComment(decl *ast.GenDecl) {
 	if decl.Doc == nil || decl.Specs == nil || len(decl.Specs) == 0 {
 		return
@@ -150,23 +188,69 @@ func (d *detected) parseComment(decl *ast.GenDecl) {
 		return
 	}
 	name := solveName(tspec.Name)
-	i := len(decl.Doc.List) - 1
-	for ; i >= 0 && empty(decl.Doc.List[i].Text); i-- {
-	}
-	if i >= 0 {
-		cmt := decl.Doc.List[i]
-		c := string(cmt.Text)
-		if strings.Contains(strings.ToLower(c), "(remotize)") {
-			if it, ok := tspec.Type.(*ast.InterfaceType); ok {
-				d.interfaces[name] = it
-				d.mark(name)
-			} else {
-				d.mark(name)
-			}
+	if settings, ok := annotation(decl.Doc); ok {
+		if it, ok := tspec.Type.(*ast.InterfaceType); ok {
+			d.interfaces[name] = it
 		}
+		d.mark(name)
+		d.annotate(name, "", settings)
 	}
 }
 
+// annotation returns the settings of a '(remotize ...)' annotation ending a comment, if any
+func annotation(doc *ast.CommentGroup) (string, bool) {
+	if doc == nil {
+		return "", false
+	}
+	i := len(doc.List) - 1
+	for ; i >= 0 && empty(doc.List[i].Text); i-- {
+	}
+	if i < 0 {
+		return "", false
+	}
+	c := strings.ToLower(string(doc.List[i].Text))
+	at := strings.Index(c, "(remotize")
+	if at < 0 {
+		return "", false
+	}
+	c = c[at+len("(remotize"):]
+	end := strings.Index(c, ")")
+	if end < 0 || (end > 0 && c[0] != ' ' && c[0] != ':') {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimLeft(c[:end], ":")), true
+}
+
+// annotate records the call policy settings annotated for a method of name
+// (or for all of them, if method is empty)
+func (d *detected) annotate(name, method, settings string) {
+	if settings == "" {
+		return
+	}
+	if d.annotated[name] == nil {
+		d.annotated[name] = make(map[string]string)
+	}
+	d.annotated[name][method] = settings
+}
+
+// locate records where a method is declared, as file:line
+func (d *detected) locate(name, method string, pos token.Pos) {
+	if d.positions[name] == nil {
+		d.positions[name] = make(map[string]string)
+	}
+	p := d.fset.Position(pos)
+	d.positions[name][method] = fmt.Sprintf("%s:%d", p.Filename, p.Line)
+}
+
+// describe records the parameter and result names and the doc comment of a method
+func (d *detected) describe(name, method string, ft *ast.FuncType, doc *ast.CommentGroup) {
+	if d.described[name] == nil {
+		d.described[name] = make(map[string]*MethodInfo)
+	}
+	d.described[name][method] = &MethodInfo{Params: paramNames(ft.Params),
+		Results: paramNames(ft.Results), Doc: docText(doc)}
+}
+
 // parseCalls will detect invocations of remotize calls like remotize.Please,
 // remotize.NewRemote, remotize.NewServiceWith or NewRemoteXXX / NewXXXService
 func (d *detected) parseCalls(call *ast.CallExpr) {
@@ -207,6 +291,49 @@ func (d *detected) recordInterfaces(tspec *ast.TypeSpec) {
 	}
 }
 
+// recordTypes will collect the concrete types declared at the top level of a source file,
+// as they might travel within interfaces
+func (d *detected) recordTypes(file *ast.File) {
+	for _, fdecl := range file.Decls {
+		gdecl, ok := fdecl.(*ast.GenDecl)
+		if !ok || gdecl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gdecl.Specs {
+			tspec := spec.(*ast.TypeSpec)
+			if _, ok := tspec.Type.(*ast.InterfaceType); !ok {
+				d.types = append(d.types, solveName(tspec.Name))
+			}
+		}
+	}
+}
+
+// impls returns the concrete types detected, prefixed by '*' when they have pointer methods
+func (d *detected) impls() []string {
+	impls := make([]string, 0)
+	for _, name := range d.types {
+		if d.methods["*"+name] != nil {
+			name = "*" + name
+		}
+		impls = append(impls, name)
+	}
+	return impls
+}
+
+// methodNames returns the names of the methods of a type as returned by impls
+func (d *detected) methodNames(name string) []string {
+	names := make([]string, 0)
+	for _, n := range []string{strings.TrimLeft(name, "*"), name} {
+		for _, fdecl := range d.methods[n] {
+			names = append(names, solveName(fdecl.Name))
+		}
+		if n == name {
+			break
+		}
+	}
+	return names
+}
+
 // recordMethods will search for method Function Declarations in the source code
 func (d *detected) recordMethods(fdecl *ast.FuncDecl) {
 	if fdecl.Recv == nil || fdecl.Name == nil ||
@@ -214,6 +341,11 @@ func (d *detected) recordMethods(fdecl *ast.FuncDecl) {
 		return
 	}
 	recv := solveName(fdecl.Recv.List[0])
+	if settings, ok := annotation(fdecl.Doc); ok {
+		d.annotate(recv, solveName(fdecl.Name), settings)
+	}
+	d.locate(recv, solveName(fdecl.Name), fdecl.Pos())
+	d.describe(recv, solveName(fdecl.Name), fdecl.Type, fdecl.Doc)
 	ml := d.methods[recv]
 	if ml == nil {
 		ml = make([]*ast.FuncDecl, 0)
@@ -234,14 +366,14 @@ func (d *detected) mark(name string) {
 	if strings.Contains(name, ".") {
 		d.decls = append(d.decls, &predefined{d, name})
 	} else {
-		d.decls = append(d.decls, &decl{d, name, false, nil, nil})
-		d.decls = append(d.decls, &decl{d, "*" + name, false, nil, nil})
+		d.decls = append(d.decls, &decl{d, name, false, nil, nil, nil, nil})
+		d.decls = append(d.decls, &decl{d, "*" + name, false, nil, nil, nil, nil})
 	}
 }
 
 // postProcess completes candidate types with their methods (retrieved by 
 // parseMethods) and pass them and the interfaces found as sources within Detected
-func postProcess(d *detected) []PreSpec {
+func postProcess(d *detected) ([]PreSpec, os.Error) {
 	prespecs := make([]PreSpec, 0)
 	for _, ps := range d.decls {
 		dcl, ok := ps.(*decl) // complete types with methods
@@ -253,13 +385,44 @@ func postProcess(d *detected) []PreSpec {
 		if d.interfaces[name] == nil && d.methods[name] != nil { // is a type declaration
 			processTypeDecl(dcl)
 		} else if it := d.interfaces[name]; it != nil { // is a interface declaration
-			processInterfaceDecl(dcl, it)
+			if e := processInterfaceDecl(dcl, it); e != nil {
+				return nil, e
+			}
 		}
 		if dcl.Src != nil {
+			dcl.infos = d.infos(name)
 			prespecs = append(prespecs, ps)
 		}
 	}
-	return prespecs
+	return prespecs, nil
+}
+
+// infos returns the source code info about the methods of name, if any
+func (d *detected) infos(name string) map[string]*MethodInfo {
+	var infos map[string]*MethodInfo
+	info := func(method string) *MethodInfo {
+		if infos == nil {
+			infos = make(map[string]*MethodInfo)
+		}
+		if infos[method] == nil {
+			infos[method] = &MethodInfo{}
+		}
+		return infos[method]
+	}
+	for _, n := range []string{strings.TrimLeft(name, "*"), name} {
+		for method, settings := range d.annotated[n] {
+			mi := info(method)
+			mi.Policy, mi.NoCheck = splitNoCheck(settings)
+		}
+		for method, pos := range d.positions[n] {
+			info(method).Pos = pos
+		}
+		for method, described := range d.described[n] {
+			mi := info(method)
+			mi.Params, mi.Results, mi.Doc = described.Params, described.Results, described.Doc
+		}
+	}
+	return infos
 }
 
 // processTypeDecl will produce an interface declaration from all the types methods
@@ -282,17 +445,73 @@ func processTypeDecl(dcl *decl) {
 	dcl.isInterface = false
 }
 
-// processInterfaceDecl will declare an interface source code
-func processInterfaceDecl(dcl *decl, it *ast.InterfaceType) {
+// processInterfaceDecl will declare an interface source code, flattening the interfaces
+// it embeds from this package so that their methods are remotized as well
+func processInterfaceDecl(dcl *decl, it *ast.InterfaceType) os.Error {
 	name:=dcl.name()
-	ast.Walk(dcl, it) // -> call dcl.Visit
+	methods := bytes.NewBufferString("")
+	e := dcl.flatten(name, it, methods, make(map[string]string), make(map[string]bool))
+	if e != nil {
+		return e
+	}
 	dcl.Src = bytes.NewBufferString("")
 	//fmt.Fprintf(dcl.Src, "%s", header(d.packname, dcl.imports))
 	fmt.Fprintf(dcl.Src, "%s", redefinedMarker)
-	fmt.Fprintf(dcl.Src, "type %s ", name)
-	printer.Fprint(dcl.Src, token.NewFileSet(), it)
-	fmt.Fprintf(dcl.Src, "\n")
+	fmt.Fprintf(dcl.Src, "type %s interface {%s\n}\n", name, methods)
 	dcl.isInterface = true
+	return nil
+}
+
+// flatten writes the methods of interface 'from' as methods of the declared one, along with
+// those of the interfaces it embeds from this package. Interfaces embedded from other
+// packages are just recorded, as their methods are only known once compiled: the remotizer
+// gets them through reflection, and reports their clashes. Methods are recorded in seen by
+// the interface declaring them, to detect any clash
+func (dcl *decl) flatten(from string, it *ast.InterfaceType, methods io.Writer,
+seen map[string]string, within map[string]bool) os.Error {
+	if within[from] {
+		return os.NewError(fmt.Sprintf("Can't remotize %s: interface %s embeds itself",
+			dcl.name(), from))
+	}
+	within[from] = true
+	defer func() {
+		within[from] = false, false
+	}()
+	ast.Walk(dcl, it) // -> call dcl.Visit
+	for _, field := range it.Methods.List { // methods may be annotated before or after
+		if len(field.Names) == 0 {
+			embedded := solveName(field.Type)
+			if eit := dcl.detected.interfaces[embedded]; eit != nil {
+				if e := dcl.flatten(embedded, eit, methods, seen, within); e != nil {
+					return e
+				}
+			} else {
+				dcl.embedded = append(dcl.embedded, embedded)
+			}
+			continue
+		}
+		method := solveName(field.Names[0])
+		if other, ok := seen[method]; ok {
+			p := dcl.detected.fset.Position(field.Pos())
+			return os.NewError(fmt.Sprintf("%s:%d: Can't remotize %s: method %s of %s "+
+				"clashes with the one of %s", p.Filename, p.Line, dcl.name(), method, from,
+				other))
+		}
+		seen[method] = from
+		settings, ok := annotation(field.Doc)
+		if !ok {
+			settings, _ = annotation(field.Comment)
+		}
+		dcl.detected.annotate(dcl.name(), method, settings)
+		dcl.detected.locate(dcl.name(), method, field.Pos())
+		if ft, ok := field.Type.(*ast.FuncType); ok {
+			dcl.detected.describe(dcl.name(), method, ft, field.Doc)
+		}
+		tmpbuf := bytes.NewBufferString("")
+		printer.Fprint(tmpbuf, token.NewFileSet(), field.Type)
+		fmt.Fprintf(methods, "\n\t%s%s", method, tmpbuf.String()[len("func"):])
+	}
+	return nil
 }
 
 // Visit parses a candidate interface source code
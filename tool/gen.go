@@ -11,6 +11,7 @@ import (
 	"io"
 	"os"
 	"reflect"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
@@ -18,6 +19,15 @@ import (
 
 const remotizePkg = "github.com/josvazg/remotize"
 
+// The Args fields carrying the nanoseconds left until the deadline of the call Context,
+// and the call id to cancel it by
+const (
+	deadlineField = "RemotizeDeadline"
+	deadlineTag   = "remotize_deadline"
+	callField     = "RemotizeCall"
+	callTag       = "remotize_call"
+)
+
 // remotizer code head and tail & marker
 const (
 	remotizerHead = `// Autogenerated Remotizer [DO NOT EDIT!]
@@ -25,11 +35,16 @@ package main
 
 `
 	remotizerTail = `func main() {
+	failed := false
 	for _,spec := range toremotize {
 		if e:=tool.Remotize(spec); e!=nil {
-			panic(e)
+			fmt.Fprintln(os.Stderr, e)
+			failed = true
 		}
 	}
+	if failed {
+		os.Exit(1)
+	}
 }`
 )
 
@@ -40,13 +55,43 @@ type Spec struct {
 	isInterface bool
 	t           reflect.Type
 	imports     map[string]string
+	infos       map[string]*MethodInfo
+	impls       []impl
+	embedded    []reflect.Type
+	file        string
+	named       bool
+	noClient    bool
+	noServer    bool
+}
+
+// MethodInfo holds what the source code tells about a method, beyond what reflection does
+type MethodInfo struct {
+	// Policy holds the call policy settings annotated as (remotize ...)
+	Policy string
+	// Pos is where the method is declared, as file:line
+	Pos string
+	// NoCheck opts the method out of the remotizability checks, as annotated by
+	// (remotize nocheck)
+	NoCheck bool
+	// Params and Results hold the parameter and result names, "" for unnamed ones
+	Params, Results []string
+	// Doc is the method doc comment text
+	Doc string
+}
+
+// impl is a concrete type from the package source code, known by its name (starting by '*'
+// if it has pointer methods) and method names, that may travel within interfaces
+type impl struct {
+	name    string
+	methods []string
 }
 
 // NewSpec will create an Spec to be remotized.
 func NewSpec(pack string, isInterface bool, i interface{}) *Spec {
 	t := reflect.TypeOf(i)
 	bt := baseType(t)
-	return &Spec{pack, bt.Name(), isInterface, bt, make(map[string]string)}
+	return &Spec{packname: pack, name: bt.Name(), isInterface: isInterface, t: bt,
+		imports: make(map[string]string)}
 }
 
 // Value2Spec turns a sample value into a remotization Spec for that kind of value.
@@ -61,7 +106,58 @@ func Type2Spec(pack string, t reflect.Type) *Spec {
 	if t.NumMethod() == 0 {
 		t = bt
 	}
-	return &Spec{pack, ifacename(bt.Name()), isInterface, t, make(map[string]string)}
+	return &Spec{packname: pack, name: ifacename(bt.Name()), isInterface: isInterface, t: t,
+		imports: make(map[string]string)}
+}
+
+// WithInfo attaches source code info about a method (or about all of them, if method is empty) 
+// to the Spec and returns it.
+func (s *Spec) WithInfo(method string, info *MethodInfo) *Spec {
+	if s.infos == nil {
+		s.infos = make(map[string]*MethodInfo)
+	}
+	s.infos[method] = info
+	return s
+}
+
+// WithImpl tells the Spec about a concrete type from the package, by name (starting by '*'
+// if it has pointer methods) and method names, and returns it. Types implementing interfaces
+// that travel within the method arguments or results are registered for gob.
+func (s *Spec) WithImpl(name string, methods ...string) *Spec {
+	s.impls = append(s.impls, impl{name, methods})
+	return s
+}
+
+// WithEmbedded tells the Spec about an interface embedded from another package, given as a
+// pointer to it, whose methods are remotized as well, and returns it.
+func (s *Spec) WithEmbedded(i interface{}) *Spec {
+	s.embedded = append(s.embedded, baseType(reflect.TypeOf(i)))
+	return s
+}
+
+// WithFile sets the file the Spec remotization is written to, instead of remotized<Name>.go,
+// and returns it.
+func (s *Spec) WithFile(filename string) *Spec {
+	if strings.HasSuffix(filename, ".go") {
+		filename = filename[:len(filename)-len(".go")]
+	}
+	s.file = filename
+	return s
+}
+
+// WithName sets the name of the interface generated for a type, instead of <Type>er, and
+// returns the Spec. Interfaces keep their own names.
+func (s *Spec) WithName(name string) *Spec {
+	s.name = name
+	s.named = true
+	return s
+}
+
+// WithSides tells whether to generate the client side code (the remote proxy) and the server
+// side code (the service) and returns the Spec. Both are generated by default.
+func (s *Spec) WithSides(client, server bool) *Spec {
+	s.noClient, s.noServer = !client, !server
+	return s
 }
 
 // Remotize remotizes a type, interface or source code specified in a Spec by generating
@@ -70,6 +166,22 @@ func Remotize(spec *Spec) os.Error {
 	if spec.name == "" {
 		return os.NewError(fmt.Sprintf("Can't remotize unnamed interface from ", spec))
 	}
+	if spec.named && spec.isInterface {
+		return os.NewError(fmt.Sprintf("Can't rename interface %s, only types", spec.t))
+	}
+	if spec.noClient && spec.noServer {
+		return os.NewError(fmt.Sprintf("Can't remotize %s generating no side at all", spec.name))
+	}
+	if e := spec.checkEmbedded(); e != nil {
+		return e
+	}
+	if _, e := spec.policies(); e != nil {
+		return os.NewError(fmt.Sprintf("Can't remotize %s: %v", spec.name, e))
+	}
+	if problems := spec.check(); len(problems) > 0 {
+		return os.NewError(fmt.Sprintf("Can't remotize %s:\n%s", spec.name,
+			strings.Join(problems, "\n")))
+	}
 	def := spec.buildInterfaceDef()
 	hdr := spec.buildHeader()
 	body := spec.buildBody()
@@ -77,7 +189,11 @@ func Remotize(spec *Spec) os.Error {
 		def = ""
 	}
 	source := fmt.Sprintf("%s%s%s", hdr, def, body)
-	return gofmtSave("remotized"+spec.name, source)
+	filename := spec.file
+	if filename == "" {
+		filename = "remotized" + spec.name
+	}
+	return gofmtSave(filename, source)
 }
 
 // nameOf returns the base NON pointer type referred by t
@@ -88,20 +204,102 @@ func baseType(t reflect.Type) reflect.Type {
 	return t
 }
 
+// methods returns the methods of the remotized type, along with those of the interfaces it
+// embeds from other packages, sorted by name as reflection does
+func (s *Spec) methods() []reflect.Method {
+	methods := make([]reflect.Method, 0)
+	seen := make(map[string]bool)
+	for _, t := range append([]reflect.Type{s.t}, s.embedded...) {
+		for i := 0; i < t.NumMethod(); i++ {
+			if m := t.Method(i); !seen[m.Name] {
+				seen[m.Name] = true
+				methods = append(methods, m)
+			}
+		}
+	}
+	sort.Sort(byName(methods))
+	return methods
+}
+
+// byName sorts methods by name
+type byName []reflect.Method
+
+func (b byName) Len() int           { return len(b) }
+func (b byName) Less(i, j int) bool { return b[i].Name < b[j].Name }
+func (b byName) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+// checkEmbedded returns an error telling the first method clashing with one of an interface
+// embedded from another package, if any, as the compiler would refuse the remotized interface
+func (s *Spec) checkEmbedded() os.Error {
+	owners := make(map[string]string)
+	for _, t := range s.embedded {
+		for i := 0; i < t.NumMethod(); i++ {
+			method := t.Method(i).Name
+			if other, ok := owners[method]; ok {
+				return os.NewError(fmt.Sprintf("Can't remotize %s: method %s of %s "+
+					"clashes with the one of %s", s.name, method, t, other))
+			}
+			owners[method] = t.String()
+		}
+	}
+	for i := 0; i < s.t.NumMethod(); i++ {
+		method := s.t.Method(i).Name
+		if other, ok := owners[method]; ok {
+			where := ""
+			if info, ok := s.infos[method]; ok && info.Pos != "" {
+				where = info.Pos + ": "
+			}
+			return os.NewError(fmt.Sprintf("%sCan't remotize %s: method %s of %s "+
+				"clashes with the one of %s", where, s.name, method, s.name, other))
+		}
+	}
+	return nil
+}
+
+// policies returns the call policies annotated for the methods (the default one keyed as "")
+func (s *Spec) policies() (map[string]remotize.Policy, os.Error) {
+	policies := make(map[string]remotize.Policy)
+	for method, info := range s.infos {
+		if info.Policy == "" {
+			continue
+		}
+		p, e := remotize.ParsePolicy(info.Policy)
+		if e != nil {
+			return nil, os.NewError(fmt.Sprintf("method %s: %v", method, e))
+		}
+		policies[method] = p
+	}
+	return policies, nil
+}
+
+// isContext returns true if t is the remotize.Context interface
+func isContext(t reflect.Type) bool {
+	return t.Kind() == reflect.Interface && t.Name() == "Context" && t.PkgPath() == remotizePkg
+}
+
+// takesContext tells whether the argument at start (the first one after the receiver, if any)
+// of method type ft is a remotize.Context
+func takesContext(ft reflect.Type, start int) bool {
+	return ft.NumIn() > start && isContext(ft.In(start))
+}
+
 // buildInterfaceDef builds the interface definition and fills the imports field
 func (s *Spec) buildInterfaceDef() string {
 	def := bytes.NewBufferString("")
 	ifacename := s.name
-	if !s.isInterface {
+	if !s.isInterface && !s.named {
 		ifacename += remotize.Suffix(s.name)
 	}
 	fmt.Fprintf(def, "type %s interface", ifacename)
-	if s.t.NumMethod() > 0 {
+	if methods := s.methods(); len(methods) > 0 {
 		fmt.Fprintf(def, " {")
-		for i := 0; i < s.t.NumMethod(); i++ {
-			m := s.t.Method(i)
+		for _, m := range methods {
 			if isExported(m.Name) {
-				fmt.Fprintf(def, "\n    ")
+				fmt.Fprintf(def, "\n")
+				if info, ok := s.infos[m.Name]; ok {
+					genDoc(def, info.Doc, "    ")
+				}
+				fmt.Fprintf(def, "    ")
 				s.funcsource(def, s.t, &m)
 			}
 		}
@@ -110,8 +308,10 @@ func (s *Spec) buildInterfaceDef() string {
 	return def.String()
 }
 
-// funcsource will generate the source code for a function declaration (no body)
+// funcsource will generate the source code for a function declaration (no body),
+// naming parameters and results as in the source code of method m, if known
 func (s *Spec) funcsource(w io.Writer, t reflect.Type, m *reflect.Method) {
+	var n *names
 	start := 0
 	if t.Kind() == reflect.Interface {
 		fmt.Fprintf(w, m.Name+"(")
@@ -122,29 +322,48 @@ func (s *Spec) funcsource(w io.Writer, t reflect.Type, m *reflect.Method) {
 		fmt.Fprintf(w, "func (")
 	}
 	if m != nil {
+		n = s.names(*m, start)
 		t = m.Type
 	}
 	for i := start; i < t.NumIn(); i++ {
-		s.typesource(w, t.In(i))
+		if n.params() != nil {
+			fmt.Fprintf(w, "%s ", n.param(i-start))
+		}
+		s.parsource(w, t, i)
 		if (i + 1) != t.NumIn() {
 			fmt.Fprintf(w, ", ")
 		}
 	}
 	fmt.Fprintf(w, ") ")
-	if t.NumOut() > 1 {
+	paren := t.NumOut() > 1 || (t.NumOut() == 1 && n.results() != nil)
+	if paren {
 		fmt.Fprintf(w, "(")
 	}
 	for i := 0; i < t.NumOut(); i++ {
+		if n.results() != nil {
+			fmt.Fprintf(w, "%s ", n.outs[i])
+		}
 		s.typesource(w, t.Out(i))
 		if (i + 1) != t.NumOut() {
 			fmt.Fprintf(w, ", ")
 		}
 	}
-	if t.NumOut() > 1 {
+	if paren {
 		fmt.Fprintf(w, ")")
 	}
 }
 
+// parsource will generate the source code for the i-th parameter type of function type t,
+// as '...T' if it is the variadic one
+func (s *Spec) parsource(w io.Writer, t reflect.Type, i int) {
+	if t.IsVariadic() && i == t.NumIn()-1 {
+		fmt.Fprintf(w, "...")
+		s.typesource(w, t.In(i).Elem())
+		return
+	}
+	s.typesource(w, t.In(i))
+}
+
 // typesource will generate the source code for a type
 func (s *Spec) typesource(w io.Writer, t reflect.Type) {
 	switch t.Kind() {
@@ -152,7 +371,14 @@ func (s *Spec) typesource(w io.Writer, t reflect.Type) {
 		fmt.Fprintf(w, "["+strconv.Itoa(t.Len())+"]")
 		s.typesource(w, t.Elem())
 	case reflect.Chan:
-		fmt.Fprintf(w, "chan ")
+		switch t.ChanDir() {
+		case reflect.RecvDir:
+			fmt.Fprintf(w, "<-chan ")
+		case reflect.SendDir:
+			fmt.Fprintf(w, "chan<- ")
+		default:
+			fmt.Fprintf(w, "chan ")
+		}
 		s.typesource(w, t.Elem())
 	case reflect.Func:
 		s.funcsource(w, t, nil)
@@ -180,6 +406,12 @@ func (s *Spec) typesource(w io.Writer, t reflect.Type) {
 	}
 }
 
+// chansource will generate the source code for a bidirectional channel type like t
+func (s *Spec) chansource(w io.Writer, t reflect.Type) {
+	fmt.Fprintf(w, "chan ")
+	s.typesource(w, t.Elem())
+}
+
 // pack will record the type's package as an import
 func (s *Spec) pack(t reflect.Type) {
 	packpath := t.PkgPath()
@@ -196,7 +428,6 @@ func (s *Spec) buildHeader() string {
 	if s.imports == nil {
 		s.imports = make(map[string]string)
 	}
-	s.imports["rpc"] = "rpc"
 	s.imports["os"] = "os"
 	typepack := baseType(s.t).PkgPath()
 	if s.packname != typepack && typepack != "main" && s.t.Kind() == reflect.Interface {
@@ -218,18 +449,31 @@ func (s *Spec) buildBody() string {
 	src := bytes.NewBufferString("")
 	fmt.Fprintf(src, "// Autoregistry\n")
 	fmt.Fprintf(src, "func init() {\n")
-	fmt.Fprintf(src, "    remotize.Register(Remote%s{},\n", s.name)
-	fmt.Fprintf(src, "        func(cli *rpc.Client) interface{} "+
-		"{\n\t\t\treturn NewRemote%s(cli)\n\t\t},\n", s.name)
-	fmt.Fprintf(src, "        %sService{},\n", s.name)
-	fmt.Fprintf(src, "        func(i interface{}) interface{} {")
-	fmt.Fprintf(src, "\n\t\t\treturn New%sService(i.(%s))\n\t\t},\n", s.name, s.fullname())
+	fmt.Fprintf(src, "    remotize.Register(new(%s),\n", s.fullname())
+	if s.noClient {
+		fmt.Fprintf(src, "        nil,\n")
+	} else {
+		fmt.Fprintf(src, "        func(cli remotize.Caller, opts ...remotize.Option) interface{} "+
+			"{\n\t\t\treturn NewRemote%s(cli, opts...)\n\t\t},\n", s.name)
+	}
+	if s.noServer {
+		fmt.Fprintf(src, "        nil,\n")
+	} else {
+		fmt.Fprintf(src, "        func(i interface{}) interface{} {")
+		fmt.Fprintf(src, "\n\t\t\treturn New%sService(i.(%s))\n\t\t},\n", s.name, s.fullname())
+	}
 	fmt.Fprintf(src, "    )\n")
+	for _, gt := range s.gobTypes() {
+		fmt.Fprintf(src, "    remotize.RegisterType(%s)\n", gt)
+	}
 	fmt.Fprintf(src, "}\n\n")
-	s.remoteInit(src)
-	s.localInit(src)
-	for i := 0; i < s.t.NumMethod(); i++ {
-		m := s.t.Method(i)
+	if !s.noServer {
+		s.remoteInit(src)
+	}
+	if !s.noClient {
+		s.localInit(src)
+	}
+	for _, m := range s.methods() {
 		if isExported(m.Name) {
 			s.wrapMethod(src, m)
 		}
@@ -237,6 +481,91 @@ func (s *Spec) buildBody() string {
 	return src.String()
 }
 
+// gobTypes returns the values to register for the package types implementing interfaces that
+// travel gob encoded within the method arguments or results, in order.
+//
+// Implementations are matched by method names, as types are only known by name from source.
+func (s *Spec) gobTypes() []string {
+	ifaces := make(map[reflect.Type]bool)
+	start := 0
+	if s.t.Kind() != reflect.Interface {
+		start = 1
+	}
+	for _, m := range s.methods() {
+		if !isExported(m.Name) {
+			continue
+		}
+		for j := start; j < m.Type.NumIn(); j++ {
+			if t := m.Type.In(j); !streamedArg(t) && !(j == start && isContext(t)) {
+				gobInterfaces(t, ifaces, make(map[reflect.Type]bool))
+			}
+		}
+		for j := 0; j < m.Type.NumOut(); j++ {
+			if t := m.Type.Out(j); !streamedResult(t) {
+				gobInterfaces(t, ifaces, make(map[reflect.Type]bool))
+			}
+		}
+	}
+	values := make([]string, 0)
+	for _, im := range s.impls {
+		for it, _ := range ifaces {
+			if !implements(im, it) {
+				continue
+			}
+			if strings.HasPrefix(im.name, "*") {
+				values = append(values, "new("+im.name[1:]+")")
+			} else {
+				values = append(values, "*new("+im.name+")")
+			}
+			break
+		}
+	}
+	sort.Strings(values)
+	return values
+}
+
+// gobInterfaces collects the interfaces, other than os.Error, travelling gob encoded within t
+func gobInterfaces(t reflect.Type, ifaces, seen map[reflect.Type]bool) {
+	if seen[t] {
+		return
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Interface:
+		if !isError(t) {
+			ifaces[t] = true
+		}
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		gobInterfaces(t.Elem(), ifaces, seen)
+	case reflect.Map:
+		gobInterfaces(t.Key(), ifaces, seen)
+		gobInterfaces(t.Elem(), ifaces, seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if f := t.Field(i); f.PkgPath == "" {
+				gobInterfaces(f.Type, ifaces, seen)
+			}
+		}
+	}
+}
+
+// implements tells whether im has all methods of interface it, by name
+func implements(im impl, it reflect.Type) bool {
+	for i := 0; i < it.NumMethod(); i++ {
+		found := false
+		for _, m := range im.methods {
+			if m == it.Method(i).Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
 // fullname return the appropiate full name with or without package prefix for the remotized type
 func (s *Spec) fullname() string {
 	if !s.isInterface || s.t.PkgPath() == s.packname || s.t.PkgPath() == "main" {
@@ -255,17 +584,80 @@ func (s *Spec) remoteInit(w io.Writer) {
 	fmt.Fprintf(w, "func New%sService(impl %s) *%sService {\n", s.name, s.fullname(), s.name)
 	fmt.Fprintf(w, "    return &%sService{impl}\n", s.name)
 	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "// Batch support for %sService\n", s.name)
+	fmt.Fprintf(w, "func (r *%sService) RemotizeBatch(args *remotize.BatchArgs, "+
+		"reply *remotize.BatchReply) os.Error {\n", s.name)
+	fmt.Fprintf(w, "\treturn remotize.ServeBatch(r, args, reply)\n}\n\n")
+	if s.hasContexts() {
+		fmt.Fprintf(w, "// Cancellation support for %sService\n", s.name)
+		fmt.Fprintf(w, "func (r *%sService) RemotizeCancel(args, reply *remotize.CancelData) "+
+			"os.Error {\n", s.name)
+		fmt.Fprintf(w, "\treturn remotize.ServeCancel(args, reply)\n}\n\n")
+	}
+	if s.hasStreams() {
+		fmt.Fprintf(w, "// Stream support for %sService\n", s.name)
+		for _, op := range []string{"Open", "Pull", "Push"} {
+			fmt.Fprintf(w, "func (r *%sService) Remotize%s(args, reply *remotize.StreamData) "+
+				"os.Error {\n", s.name, op)
+			fmt.Fprintf(w, "\treturn remotize.Serve%s(args, reply)\n}\n\n", op)
+		}
+	}
 }
 
 // localInit prepares the client header
 func (s *Spec) localInit(w io.Writer) {
 	fmt.Fprintf(w, "// Rpc client for %s\n", s.name)
 	fmt.Fprintf(w, "type Remote%s struct {\n", s.name)
-	fmt.Fprintf(w, "    cli *rpc.Client\n")
+	fmt.Fprintf(w, "    cli  remotize.Caller\n")
+	fmt.Fprintf(w, "    opts *remotize.Options\n")
 	fmt.Fprintf(w, "}\n\n")
 	fmt.Fprintf(w, "// Direct Remote%s constructor\n", s.name)
-	fmt.Fprintf(w, "func NewRemote%s(cli *rpc.Client) *Remote%s {\n", s.name, s.name)
-	fmt.Fprintf(w, "    return &Remote%s{cli}\n", s.name)
+	fmt.Fprintf(w, "func NewRemote%s(cli remotize.Caller, opts ...remotize.Option) *Remote%s {\n",
+		s.name, s.name)
+	policies, _ := s.policies()
+	if len(policies) == 0 {
+		fmt.Fprintf(w, "    return &Remote%s{cli, remotize.NewOptions(opts...)}\n", s.name)
+	} else { // annotated policies go first, so that opts can override them
+		fmt.Fprintf(w, "    return &Remote%s{cli, remotize.NewOptions(append([]remotize.Option{\n",
+			s.name)
+		methods := make([]string, 0)
+		for method, _ := range policies {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+		for _, method := range methods {
+			p := policies[method]
+			fmt.Fprintf(w, "\t\tremotize.WithPolicy(\"%s\", remotize.Policy{Timeout: %d, "+
+				"Retries: %d, Backoff: %d, Idempotent: %v}),\n",
+				method, p.Timeout, p.Retries, p.Backoff, p.Idempotent)
+		}
+		fmt.Fprintf(w, "\t}, opts...)...)}\n")
+	}
+	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "// Sets the call policy for method (or the default one, if empty) ")
+	fmt.Fprintf(w, "before using the proxy\n")
+	fmt.Fprintf(w, "func (l *Remote%s) RemotizePolicy(method string, p remotize.Policy) {\n", s.name)
+	fmt.Fprintf(w, "    remotize.WithPolicy(method, p)(l.opts)\n")
+	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "// Closes the proxy's connection, releasing the remote object, if any\n")
+	fmt.Fprintf(w, "func (l *Remote%s) RemotizeRelease() os.Error {\n", s.name)
+	fmt.Fprintf(w, "    return remotize.Disconnect(l.cli)\n")
+	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "// Batch builder for %s, packing many calls in a single round-trip\n", s.name)
+	fmt.Fprintf(w, "type %sBatch struct {\n", s.name)
+	fmt.Fprintf(w, "    batch *remotize.Batch\n")
+	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "// Returns how many calls are waiting to be sent\n")
+	fmt.Fprintf(w, "func (b *%sBatch) Len() int {\n", s.name)
+	fmt.Fprintf(w, "    return b.batch.Len()\n")
+	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "// Sends all queued calls in a single round-trip, returning their results in order\n")
+	fmt.Fprintf(w, "func (b *%sBatch) Send() ([]remotize.BatchResult, os.Error) {\n", s.name)
+	fmt.Fprintf(w, "    return b.batch.Send()\n")
+	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "// Returns a new empty batch of calls to the remote %s\n", s.name)
+	fmt.Fprintf(w, "func (l *Remote%s) Batch() *%sBatch {\n", s.name, s.name)
+	fmt.Fprintf(w, "    return &%sBatch{l.opts.NewBatch(l.cli, \"%sService\")}\n", s.name, s.name)
 	fmt.Fprintf(w, "}\n\n")
 }
 
@@ -277,54 +669,150 @@ func (s *Spec) wrapMethod(w io.Writer, m reflect.Method) {
 	if s.t.Kind() != reflect.Interface { // avoid the first receiver arg on non interfaces
 		start = 1
 	}
+	nm := s.names(m, start)
+	if nm.context() != "" { // the Context doesn't travel, just the time left until its deadline
+		start++
+	}
 	for i := start; i < m.Type.NumIn(); i++ {
 		args = append(args, m.Type.In(i))
 	}
-	s.generateStructWrapper(w, args, "Args", m.Name)
-	results, inouts := prepareInOuts(m.Type, start)
-	s.generateStructWrapper(w, results, "Reply", m.Name)
-	s.generateServerRPCWrapper(w, m, inouts, start)
-	s.generateClientRPCWrapper(w, m, inouts, start)
+	fields, tags := nm.args(len(args))
+	if nm.context() != "" {
+		args = append(args, reflect.TypeOf(int64(0)), reflect.TypeOf(uint64(0)))
+		fields = append(fields, deadlineField, callField)
+		tags = append(tags, deadlineTag, callTag)
+	}
+	s.generateStructWrapper(w, args, "Args", m.Name, fields, tags, streamedArg)
+	results, _ := prepareInOuts(m.Type, start)
+	fields, tags = nm.replies(len(results))
+	s.generateStructWrapper(w, results, "Reply", m.Name, fields, tags, streamedResult)
+	for i := start; i < m.Type.NumIn(); i++ {
+		if t := m.Type.In(i); t.Kind() == reflect.Func {
+			s.generateCallback(w, fmt.Sprintf("%sArg%dCallback", m.Name, i-start), t)
+		}
+	}
+	if !s.noServer {
+		s.generateServerRPCWrapper(w, m, nm, start)
+	}
+	if s.noClient {
+		fmt.Fprintf(w, "\n")
+		return
+	}
+	s.generateClientRPCWrapper(w, m, nm, start)
+	if !streams(m.Type, start) && nm.context() == "" {
+		s.generateAsyncWrapper(w, m, nm, start)
+		s.generateBatchWrapper(w, m, nm, start)
+	}
 	fmt.Fprintf(w, "\n")
 }
 
 // generateStructWrapper generates a argument or result struct
-func (s *Spec) generateStructWrapper(w io.Writer, pars []reflect.Type, structname, name string) {
+//
+// The types the streamed func tells apart (if not nil) travel as stream ids.
+// Fields are tagged so that JSON clients see them as named in the source code, or
+// as arg0, arg1... if not named there.
+func (s *Spec) generateStructWrapper(w io.Writer, pars []reflect.Type, structname, name string,
+fields, tags []string, streamed func(reflect.Type) bool) {
 	fmt.Fprintf(w, "type %s%s%s struct {\n", s.name, name, structname)
 	for i, par := range pars {
-		fmt.Fprintf(w, "\tArg%d ", i)
-		s.typesource(w, par)
-		fmt.Fprintf(w, "\n")
+		fmt.Fprintf(w, "\t%s ", fields[i])
+		if streamed != nil && streamed(par) {
+			fmt.Fprintf(w, "uint64")
+		} else {
+			s.typesource(w, par)
+		}
+		fmt.Fprintf(w, " `json:\"%s\"`\n", tags[i])
 	}
 	fmt.Fprintf(w, "}\n\n")
 }
 
 // prepareInOuts detects how many pointers in the args must be returned as results (in & outs)
+//
+// Results go first in the reply, followed by the pointers to be returned, whose
+// argument positions (not counting the receiver) are returned as inouts.
 func prepareInOuts(ft reflect.Type, start int) ([]reflect.Type, []int) {
 	results := make([]reflect.Type, 0)
 	inouts := make([]int, 0)
+	for i := 0; i < ft.NumOut(); i++ {
+		results = append(results, ft.Out(i))
+	}
 	for i := start; i < ft.NumIn(); i++ {
 		if ft.In(i).Kind() == reflect.Ptr {
 			results = append(results, ft.In(i))
-			inouts = append(inouts, i)
+			inouts = append(inouts, i-start)
 		}
 	}
-	for i := 0; i < ft.NumOut(); i++ {
-		results = append(results, ft.Out(i))
-	}
 	return results, inouts
 }
 
 // function that is exposed to an RPC API, but calls simple "Server_" one
-func (s *Spec) generateServerRPCWrapper(w io.Writer, m reflect.Method, inouts []int, start int) {
+// through the server interceptor chain
+func (s *Spec) generateServerRPCWrapper(w io.Writer, m reflect.Method, nm *names, start int) {
 	name := m.Name
+	inouts := nm.inouts
 	ins := m.Type.NumIn()
 	outs := m.Type.NumOut()
 	fmt.Fprintf(w, "func (r *%sService) %s(args *%s%sArgs, "+
 		"reply *%s%sReply) os.Error {\n", s.name, name, s.name, name, s.name, name)
+	fmt.Fprintf(w, "\treturn remotize.ServeCall(\"%sService\", \"%s\", args, reply, "+
+		"func() os.Error {\n", s.name, name)
+	for i := start; i < ins; i++ {
+		if t := m.Type.In(i); t.Kind() == reflect.Chan { // a zero id stands for a nil channel
+			n := i - start
+			fmt.Fprintf(w, "\tvar c%d ", n)
+			s.chansource(w, t)
+			fmt.Fprintf(w, "\n\tif args.%s != 0 {\n", nm.argField(n))
+			fmt.Fprintf(w, "\t\ts%d, err := remotize.FindStream(args.%s)\n", n, nm.argField(n))
+			fmt.Fprintf(w, "\t\tif err != nil {\n\t\t\treturn err\n\t\t}\n")
+			fmt.Fprintf(w, "\t\tc%d = make(", n)
+			s.chansource(w, t)
+			fmt.Fprintf(w, ")\n")
+			fmt.Fprintf(w, "\t\tgo remotize.%s(s%d, c%d)\n\t}\n", serverPump(upstream(t, false)),
+				n, n)
+		} else if isCallback(t) { // callbacks are only reachable until the call returns
+			n := i - start
+			fmt.Fprintf(w, "\tvar c%d ", n)
+			s.typesource(w, t)
+			fmt.Fprintf(w, "\n\tif args.%s != 0 {\n", nm.argField(n))
+			if t.Kind() == reflect.Func {
+				fmt.Fprintf(w, "\t\tcli%d, err := remotize.DialCallback(args.%s)\n", n,
+					nm.argField(n))
+				fmt.Fprintf(w, "\t\tif err != nil {\n\t\t\treturn err\n\t\t}\n")
+				fmt.Fprintf(w, "\t\tdefer cli%d.Close()\n", n)
+				fmt.Fprintf(w, "\t\tc%d = new%s%sArg%dCallback(cli%d)\n", n, s.name, name, n, n)
+			} else {
+				fmt.Fprintf(w, "\t\tr%d, err := remotize.RemoteCallback(args.%s, new(", n,
+					nm.argField(n))
+				s.typesource(w, t)
+				fmt.Fprintf(w, "))\n\t\tif err != nil {\n\t\t\treturn err\n\t\t}\n")
+				fmt.Fprintf(w, "\t\tdefer remotize.Release(r%d)\n", n)
+				fmt.Fprintf(w, "\t\tc%d = r%d.(", n, n)
+				s.typesource(w, t)
+				fmt.Fprintf(w, ")\n")
+			}
+			fmt.Fprintf(w, "\t}\n")
+		}
+	}
+	for i := 0; i < outs; i++ {
+		if t := m.Type.Out(i); streamedResult(t) {
+			fmt.Fprintf(w, "\tvar o%d ", i)
+			s.typesource(w, t)
+			fmt.Fprintf(w, "\n")
+		}
+	}
+	if nm.context() != "" {
+		fmt.Fprintf(w, "\tctx, cancel := remotize.ServerContext(args.%s, args.%s)\n",
+			deadlineField, callField)
+		fmt.Fprintf(w, "\tdefer cancel()\n")
+	}
 	fmt.Fprintf(w, "\t")
 	for i := 0; i < outs; i++ {
-		fmt.Fprintf(w, "reply.Arg%d", i)
+		if streamedResult(m.Type.Out(i)) {
+			fmt.Fprintf(w, "o%d", i)
+		} else {
+			field, _ := nm.replyField(i)
+			fmt.Fprintf(w, "reply.%s", field)
+		}
 		if i != outs-1 {
 			fmt.Fprintf(w, ", ")
 		}
@@ -333,59 +821,488 @@ func (s *Spec) generateServerRPCWrapper(w io.Writer, m reflect.Method, inouts []
 		fmt.Fprintf(w, " = ")
 	}
 	fmt.Fprintf(w, "r.srv.%s(", name)
+	if nm.context() != "" {
+		fmt.Fprintf(w, "ctx")
+		if start < ins {
+			fmt.Fprintf(w, ", ")
+		}
+	}
 	for i := start; i < ins; i++ {
-		fmt.Fprintf(w, "args.Arg%d", i-start)
+		if streamedArg(m.Type.In(i)) {
+			fmt.Fprintf(w, "c%d", i-start)
+		} else {
+			fmt.Fprint(w, fromWire(m.Type.In(i), "args."+nm.argField(i-start)))
+		}
+		if m.Type.IsVariadic() && i == ins-1 {
+			fmt.Fprintf(w, "...")
+		}
 		if i != ins-1 {
 			fmt.Fprintf(w, ", ")
 		}
 	}
 	fmt.Fprintf(w, ")\n")
-	for i := outs; i < len(inouts); i++ {
-		fmt.Fprintf(w, "\treply.Arg%d=args.Arg%d\n", i, inouts[i-outs])
+	for i := 0; i < outs; i++ {
+		field, _ := nm.replyField(i)
+		if t := m.Type.Out(i); t.Kind() == reflect.Chan {
+			fmt.Fprintf(w, "\tif o%d != nil {\n", i)
+			fmt.Fprintf(w, "\t\ts := remotize.NewStream()\n")
+			fmt.Fprintf(w, "\t\tgo remotize.%s(s, o%d)\n", serverPump(upstream(t, true)), i)
+			fmt.Fprintf(w, "\t\treply.%s = s.Id()\n\t}\n", field)
+		} else if isRemoteIface(t) {
+			fmt.Fprintf(w, "\tif o%d != nil {\n", i)
+			fmt.Fprintf(w, "\t\tid, err := remotize.ServeObject(remotize.NewServiceWith(new(")
+			s.typesource(w, t)
+			fmt.Fprintf(w, "), o%d))\n", i)
+			fmt.Fprintf(w, "\t\tif err != nil {\n\t\t\treturn err\n\t\t}\n")
+			fmt.Fprintf(w, "\t\treply.%s = id\n\t}\n", field)
+		} else if isError(t) {
+			fmt.Fprintf(w, "\treply.%s = remotize.ToRemoteError(reply.%s)\n", field, field)
+		}
 	}
-	fmt.Fprintf(w, "\treturn nil\n}\n\n")
+	for i, arg := range inouts {
+		field, _ := nm.replyField(outs + i)
+		fmt.Fprintf(w, "\treply.%s = args.%s\n", field, nm.argField(arg))
+	}
+	fmt.Fprintf(w, "\treturn nil\n\t})\n}\n\n")
 }
 
 // generateClientRPCWrapper generates the client side wrapper
-func (s *Spec) generateClientRPCWrapper(w io.Writer, m reflect.Method, inouts []int, start int) {
+func (s *Spec) generateClientRPCWrapper(w io.Writer, m reflect.Method, nm *names, start int) {
 	name := m.Name
+	inouts := nm.inouts
 	ins := m.Type.NumIn()
 	outs := m.Type.NumOut()
+	if info, ok := s.infos[name]; ok {
+		genDoc(w, info.Doc, "")
+	}
 	fmt.Fprintf(w, "func (l *Remote%s) %s(", s.name, name)
-	s.printFuncFieldListUsingArgs(w, m.Type, start)
+	if nm.context() != "" {
+		fmt.Fprintf(w, "%s ", nm.context())
+		s.typesource(w, m.Type.In(start-1))
+		if start < ins {
+			fmt.Fprintf(w, ", ")
+		}
+	}
+	s.printFuncFieldListUsingArgs(w, m.Type, start, nm)
 	fmt.Fprintf(w, ") ")
-	s.printFuncResultList(w, m.Type)
+	s.printFuncResultList(w, m.Type, nm)
 	fmt.Fprintf(w, "{\n")
 	fmt.Fprintf(w, "\tvar args %s%sArgs\n", s.name, name)
 	fmt.Fprintf(w, "\tvar reply %s%sReply\n", s.name, name)
+	declared := false
 	for i := start; i < ins; i++ {
-		fmt.Fprintf(w, "\targs.Arg%d = Arg%d\n", i-start, i-start)
+		if isCallback(m.Type.In(i)) {
+			fmt.Fprintf(w, "\tvar err os.Error\n")
+			declared = true
+			break
+		}
 	}
-	fmt.Fprintf(w, "\terr := l.cli.Call(\"%sService.%s\", &args, &reply)\n", s.name, name)
-	fmt.Fprintf(w, "\tif err != nil {\n")
-	fmt.Fprintf(w, "\t\tpanic(err.String())\n\t}\n")
-	for i := outs; i < len(inouts); i++ {
-		fmt.Fprintf(w, "\t*Arg%d=*reply.Arg%d\n", i, inouts[i-outs])
+	for i := start; i < ins; i++ {
+		n := i - start
+		if t := m.Type.In(i); isCallback(t) {
+			fmt.Fprintf(w, "\tif %s != nil {\n", nm.arg(n))
+			fmt.Fprintf(w, "\t\targs.%s, err = remotize.ServeCallback(l.cli, \"%sService\", ",
+				nm.argField(n), s.name)
+			if t.Kind() == reflect.Func {
+				fmt.Fprintf(w, "&%s%sArg%dCallback{%s})\n", s.name, name, n, nm.arg(n))
+			} else {
+				fmt.Fprintf(w, "remotize.NewServiceWith(new(")
+				s.typesource(w, t)
+				fmt.Fprintf(w, "), %s))\n", nm.arg(n))
+			}
+			fmt.Fprintf(w, "\t}\n")
+			s.generateFailure(w, m, nm)
+		} else if t.Kind() == reflect.Chan { // nil channels travel as a zero id
+			fmt.Fprintf(w, "\tif %s != nil {\n", nm.arg(n))
+			fmt.Fprintf(w, "\t\ts%d, err := remotize.OpenStream(l.cli, \"%sService\")\n", n,
+				s.name)
+			s.generateFailure(w, m, nm)
+			fmt.Fprintf(w, "\t\targs.%s = s%d.Id\n", nm.argField(n), n)
+			fmt.Fprintf(w, "\t\tgo remotize.%s(s%d, %s)\n\t}\n", clientPump(upstream(t, false)), n,
+				nm.arg(n))
+		} else {
+			fmt.Fprintf(w, "\targs.%s = %s\n", nm.argField(n), toWire(t, nm.arg(n)))
+		}
+	}
+	assign := ":="
+	if declared {
+		assign = "="
+	}
+	if nm.context() != "" {
+		fmt.Fprintf(w, "\targs.%s = remotize.Remaining(%s)\n", deadlineField, nm.context())
+		fmt.Fprintf(w, "\targs.%s = remotize.NewCallId()\n", callField)
+		fmt.Fprintf(w, "\terr %s l.opts.CallContext(%s, args.%s, l.cli, \"%sService.%s\", "+
+			"&args, &reply)\n", assign, nm.context(), callField, s.name, name)
+	} else {
+		fmt.Fprintf(w, "\terr %s l.opts.Call(l.cli, \"%sService.%s\", &args, &reply)\n",
+			assign, s.name, name)
+	}
+	s.generateFailure(w, m, nm)
+	for i, arg := range inouts {
+		field, _ := nm.replyField(outs + i)
+		fmt.Fprintf(w, "\t*%s = *reply.%s\n", nm.arg(arg), field)
+	}
+	for i := 0; i < outs; i++ {
+		field, _ := nm.replyField(i)
+		if t := m.Type.Out(i); t.Kind() == reflect.Chan {
+			fmt.Fprintf(w, "\tvar r%d ", i)
+			s.chansource(w, t)
+			fmt.Fprintf(w, "\n\tif reply.%s != 0 {\n", field)
+			fmt.Fprintf(w, "\t\tr%d = make(", i)
+			s.chansource(w, t)
+			fmt.Fprintf(w, ")\n\t\tgo remotize.%s(remotize.DialStream(l.cli, \"%sService\", "+
+				"reply.%s), r%d)\n\t}\n", clientPump(upstream(t, true)), s.name, field, i)
+		} else if isRemoteIface(t) {
+			fmt.Fprintf(w, "\tvar r%d ", i)
+			s.typesource(w, t)
+			fmt.Fprintf(w, "\n\tif reply.%s != 0 {\n", field)
+			fmt.Fprintf(w, "\t\to%d, err := remotize.RemoteObject(l.cli, \"%sService\", "+
+				"reply.%s, new(", i, s.name, field)
+			s.typesource(w, t)
+			fmt.Fprintf(w, "), remotize.Inherit(l.opts))\n")
+			s.generateFailure(w, m, nm)
+			fmt.Fprintf(w, "\t\tr%d = o%d.(", i, i)
+			s.typesource(w, t)
+			fmt.Fprintf(w, ")\n\t}\n")
+		}
 	}
 	fmt.Fprintf(w, "\treturn ")
+	printReplyResults(w, m.Type, nm, "r")
+	fmt.Fprintf(w, "\n}\n\n")
+}
+
+// generateAsyncWrapper generates the asynchronous variant of the client wrapper, returning 
+// a typed future whose Wait returns the method results
+func (s *Spec) generateAsyncWrapper(w io.Writer, m reflect.Method, nm *names, start int) {
+	name := m.Name
+	inouts := nm.inouts
+	ins := m.Type.NumIn()
+	outs := m.Type.NumOut()
+	future := s.name + name + "Future"
+	fmt.Fprintf(w, "// Future for an asynchronous %s call\n", name)
+	fmt.Fprintf(w, "type %s struct {\n", future)
+	fmt.Fprintf(w, "\t*remotize.Future\n")
+	fmt.Fprintf(w, "\tl     *Remote%s\n", s.name)
+	fmt.Fprintf(w, "\treply %s%sReply\n", s.name, name)
+	for _, arg := range inouts {
+		fmt.Fprintf(w, "\tArg%d  ", arg)
+		s.typesource(w, m.Type.In(arg+start))
+		fmt.Fprintf(w, "\n")
+	}
+	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "func (l *Remote%s) %sAsync(", s.name, name)
+	s.printFuncFieldListUsingArgs(w, m.Type, start, nm)
+	fmt.Fprintf(w, ") *%s {\n", future)
+	fmt.Fprintf(w, "\tvar args %s%sArgs\n", s.name, name)
+	for i := start; i < ins; i++ {
+		fmt.Fprintf(w, "\targs.%s = %s\n", nm.argField(i-start),
+			toWire(m.Type.In(i), nm.arg(i-start)))
+	}
+	fmt.Fprintf(w, "\tf := &%s{l: l}\n", future)
+	for _, arg := range inouts {
+		fmt.Fprintf(w, "\tf.Arg%d = %s\n", arg, nm.arg(arg))
+	}
+	fmt.Fprintf(w, "\tf.Future = l.opts.Go(l.cli, \"%sService.%s\", &args, &f.reply)\n",
+		s.name, name)
+	fmt.Fprintf(w, "\treturn f\n}\n\n")
+	fmt.Fprintf(w, "// Wait waits for the %s call to complete and returns its results\n", name)
+	fmt.Fprintf(w, "func (f *%s) Wait() ", future)
+	s.printFuncResultList(w, m.Type, nm)
+	fmt.Fprintf(w, "{\n")
+	if outs == 0 || !isError(m.Type.Out(outs-1)) { // failures are handed to the proxy's options
+		fmt.Fprintf(w, "\tl := f.l\n")
+	}
+	if outs > 0 || len(inouts) > 0 {
+		fmt.Fprintf(w, "\treply := &f.reply\n")
+	}
+	fmt.Fprintf(w, "\terr := f.Future.Wait()\n")
+	s.generateFailure(w, m, nm)
+	for i, arg := range inouts {
+		field, _ := nm.replyField(outs + i)
+		fmt.Fprintf(w, "\t*f.Arg%d = *reply.%s\n", arg, field)
+	}
+	fmt.Fprintf(w, "\treturn ")
+	printReplyResults(w, m.Type, nm, "")
+	fmt.Fprintf(w, "\n}\n\n")
+}
+
+// generateBatchWrapper generates the batch builder method queueing a call
+func (s *Spec) generateBatchWrapper(w io.Writer, m reflect.Method, nm *names, start int) {
+	name := m.Name
+	inouts := nm.inouts
+	ins := m.Type.NumIn()
+	outs := m.Type.NumOut()
+	fmt.Fprintf(w, "func (b *%sBatch) %s(", s.name, name)
+	s.printFuncFieldListUsingArgs(w, m.Type, start, nm)
+	fmt.Fprintf(w, ") *%sBatch {\n", s.name)
+	fmt.Fprintf(w, "\targs := new(%s%sArgs)\n", s.name, name)
+	for i := start; i < ins; i++ {
+		fmt.Fprintf(w, "\targs.%s = %s\n", nm.argField(i-start),
+			toWire(m.Type.In(i), nm.arg(i-start)))
+	}
+	fmt.Fprintf(w, "\treply := new(%s%sReply)\n", s.name, name)
+	fmt.Fprintf(w, "\tb.batch.Queue(\"%s\", args, reply, func() ([]interface{}, os.Error) {\n", name)
+	for i, arg := range inouts {
+		field, _ := nm.replyField(outs + i)
+		fmt.Fprintf(w, "\t\t*%s = *reply.%s\n", nm.arg(arg), field)
+	}
+	if outs == 0 {
+		fmt.Fprintf(w, "\t\treturn nil, nil\n")
+	} else {
+		fmt.Fprintf(w, "\t\treturn []interface{}{")
+		printReplyResults(w, m.Type, nm, "")
+		if isError(m.Type.Out(outs - 1)) {
+			field, _ := nm.replyField(outs - 1)
+			fmt.Fprintf(w, "}, remotize.FromRemoteError(reply.%s)\n", field)
+		} else {
+			fmt.Fprintf(w, "}, nil\n")
+		}
+	}
+	fmt.Fprintf(w, "\t})\n")
+	fmt.Fprintf(w, "\treturn b\n}\n\n")
+}
+
+// generateCallback generates the adapter serving a func argument as a callback on the client
+// and the builder of the func stub calling it back from the server
+func (s *Spec) generateCallback(w io.Writer, name string, ft reflect.Type) {
+	ins := ft.NumIn()
+	outs := ft.NumOut()
+	fmt.Fprintf(w, "// Callback adapter for %s\n", name)
+	fmt.Fprintf(w, "type %s%s struct {\n", s.name, name)
+	fmt.Fprintf(w, "\tf ")
+	s.typesource(w, ft)
+	fmt.Fprintf(w, "\n}\n\n")
+	pars := make([]reflect.Type, 0)
+	for i := 0; i < ins; i++ {
+		pars = append(pars, ft.In(i))
+	}
+	fields, tags := (*names)(nil).args(len(pars))
+	s.generateStructWrapper(w, pars, "Args", name, fields, tags, nil)
+	results := make([]reflect.Type, 0)
+	for i := 0; i < outs; i++ {
+		results = append(results, ft.Out(i))
+	}
+	fields, tags = (*names)(nil).replies(len(results))
+	s.generateStructWrapper(w, results, "Reply", name, fields, tags, nil)
+	fmt.Fprintf(w, "func (c *%s%s) Call(args *%s%sArgs, reply *%s%sReply) os.Error {\n",
+		s.name, name, s.name, name, s.name, name)
+	fmt.Fprintf(w, "\t")
 	for i := 0; i < outs; i++ {
 		fmt.Fprintf(w, "reply.Arg%d", i)
 		if i != outs-1 {
 			fmt.Fprintf(w, ", ")
 		}
 	}
-	fmt.Fprintf(w, "\n}\n\n")
+	if outs > 0 {
+		fmt.Fprintf(w, " = ")
+	}
+	fmt.Fprintf(w, "c.f(")
+	for i := 0; i < ins; i++ {
+		fmt.Fprint(w, fromWire(ft.In(i), fmt.Sprintf("args.Arg%d", i)))
+		if ft.IsVariadic() && i == ins-1 {
+			fmt.Fprintf(w, "...")
+		}
+		if i != ins-1 {
+			fmt.Fprintf(w, ", ")
+		}
+	}
+	fmt.Fprintf(w, ")\n")
+	for i := 0; i < outs; i++ {
+		if isError(ft.Out(i)) {
+			fmt.Fprintf(w, "\treply.Arg%d = remotize.ToRemoteError(reply.Arg%d)\n", i, i)
+		}
+	}
+	fmt.Fprintf(w, "\treturn nil\n}\n\n")
+	fmt.Fprintf(w, "func new%s%s(cli remotize.Caller) ", s.name, name)
+	s.typesource(w, ft)
+	fmt.Fprintf(w, " {\n\treturn func(")
+	s.printFuncFieldListUsingArgs(w, ft, 0, nil)
+	fmt.Fprintf(w, ") ")
+	s.printFuncResultList(w, ft, nil)
+	fmt.Fprintf(w, "{\n")
+	fmt.Fprintf(w, "\t\tvar args %s%sArgs\n", s.name, name)
+	fmt.Fprintf(w, "\t\tvar reply %s%sReply\n", s.name, name)
+	for i := 0; i < ins; i++ {
+		fmt.Fprintf(w, "\t\targs.Arg%d = %s\n", i, toWire(ft.In(i), fmt.Sprintf("Arg%d", i)))
+	}
+	fmt.Fprintf(w, "\t\terr := cli.Call(\"%s%s.Call\", &args, &reply)\n", s.name, name)
+	fmt.Fprintf(w, "\t\tif err != nil {\n")
+	if outs > 0 && isError(ft.Out(outs-1)) {
+		fmt.Fprintf(w, "\t\t\treturn ")
+		for i := 0; i < outs-1; i++ {
+			printReplyResult(w, ft, i, nil, "")
+			fmt.Fprintf(w, ", ")
+		}
+		fmt.Fprintf(w, "err\n")
+	} else {
+		fmt.Fprintf(w, "\t\t\tremotize.OnCallbackError(\"%s%s.Call\", err)\n", s.name, name)
+	}
+	fmt.Fprintf(w, "\t\t}\n\t\treturn ")
+	printReplyResults(w, ft, nil, "")
+	fmt.Fprintf(w, "\n\t}\n}\n\n")
 }
 
-// printFuncFieldListUsingArgs generates the func field list with argX names
-func (s *Spec) printFuncFieldListUsingArgs(w io.Writer, t reflect.Type, start int) {
-	if t.IsVariadic() {
-		panic(fmt.Sprintf("Variadic argument lists as in '%v' are not supported!", t))
+// generateFailure generates the client side handling of a failed call
+func (s *Spec) generateFailure(w io.Writer, m reflect.Method, nm *names) {
+	outs := m.Type.NumOut()
+	fmt.Fprintf(w, "\tif err != nil {\n")
+	if outs > 0 && isError(m.Type.Out(outs-1)) {
+		// the transport error is folded into the error result
+		fmt.Fprintf(w, "\t\treturn ")
+		for i := 0; i < outs-1; i++ {
+			printReplyResult(w, m.Type, i, nm, "nil")
+			fmt.Fprintf(w, ", ")
+		}
+		fmt.Fprintf(w, "err\n\t}\n")
+	} else {
+		fmt.Fprintf(w, "\t\tl.opts.Fail(\"%sService.%s\", err)\n", s.name, m.Name)
+		fmt.Fprintf(w, "\t\treturn ")
+		printReplyResults(w, m.Type, nm, "nil")
+		fmt.Fprintf(w, "\n\t}\n")
 	}
+}
+
+// printReplyResults prints the reply fields returned as results by a client wrapper
+func printReplyResults(w io.Writer, ft reflect.Type, nm *names, chanvar string) {
+	outs := ft.NumOut()
+	for i := 0; i < outs; i++ {
+		printReplyResult(w, ft, i, nm, chanvar)
+		if i != outs-1 {
+			fmt.Fprintf(w, ", ")
+		}
+	}
+}
+
+// printReplyResult prints the i-th reply field, or the given chanvar (plus the index, 
+// unless it is nil) for streamed results. An empty chanvar means nothing is streamed.
+func printReplyResult(w io.Writer, ft reflect.Type, i int, nm *names, chanvar string) {
+	switch {
+	case !streamedResult(ft.Out(i)) || chanvar == "":
+		field, _ := nm.replyField(i)
+		fmt.Fprint(w, fromWire(ft.Out(i), "reply."+field))
+	case chanvar == "nil":
+		fmt.Fprintf(w, "nil")
+	default:
+		fmt.Fprintf(w, "%s%d", chanvar, i)
+	}
+}
+
+// toWire returns the source sending value v of type t, as errors travel as RemoteErrors
+func toWire(t reflect.Type, v string) string {
+	if isError(t) {
+		return "remotize.ToRemoteError(" + v + ")"
+	}
+	return v
+}
+
+// fromWire returns the source receiving value v of type t, turning RemoteErrors back
+// into the errors they stand for
+func fromWire(t reflect.Type, v string) string {
+	if isError(t) {
+		return "remotize.FromRemoteError(" + v + ")"
+	}
+	return v
+}
+
+// upstream tells whether the values of a streamed channel parameter or result 
+// go from the client to the server
+func upstream(t reflect.Type, isResult bool) bool {
+	if isResult {
+		return t.ChanDir() == reflect.SendDir
+	}
+	return t.ChanDir() != reflect.SendDir
+}
+
+// serverPump returns the remotize call pumping a channel on the server side
+func serverPump(up bool) string {
+	if up {
+		return "RecvChan"
+	}
+	return "SendChan"
+}
+
+// clientPump returns the remotize call pumping a channel on the client side
+func clientPump(up bool) string {
+	return serverPump(!up)
+}
+
+// isRemoteIface tells whether t is a non empty interface (other than os.Error), which 
+// are called back when passed as arguments and kept as remote objects when returned
+func isRemoteIface(t reflect.Type) bool {
+	return t.Kind() == reflect.Interface && t.NumMethod() > 0 && !isError(t)
+}
+
+// isCallback tells whether a parameter of type t is called back from the server, as
+// funcs and remote interfaces are
+func isCallback(t reflect.Type) bool {
+	return t.Kind() == reflect.Func || isRemoteIface(t)
+}
+
+// streamedArg tells whether a parameter of type t travels as a stream id
+func streamedArg(t reflect.Type) bool {
+	return t.Kind() == reflect.Chan || isCallback(t)
+}
+
+// streamedResult tells whether a result of type t travels as a stream id
+func streamedResult(t reflect.Type) bool {
+	return t.Kind() == reflect.Chan || isRemoteIface(t)
+}
+
+// hasStreams returns true if any remotized method streams channels, callbacks or objects
+func (s *Spec) hasStreams() bool {
+	start := 0
+	if s.t.Kind() != reflect.Interface {
+		start = 1
+	}
+	for _, m := range s.methods() {
+		if isExported(m.Name) && streams(m.Type, start) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasContexts returns true if any method takes a Context
+func (s *Spec) hasContexts() bool {
+	start := 0
+	if s.t.Kind() != reflect.Interface {
+		start = 1
+	}
+	for _, m := range s.methods() {
+		if isExported(m.Name) && takesContext(m.Type, start) {
+			return true
+		}
+	}
+	return false
+}
+
+// streams returns true if method type mt streams channels, callbacks or objects
+func streams(mt reflect.Type, start int) bool {
+	for j := start; j < mt.NumIn(); j++ {
+		if streamedArg(mt.In(j)) {
+			return true
+		}
+	}
+	for j := 0; j < mt.NumOut(); j++ {
+		if streamedResult(mt.Out(j)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isError returns true if t is the os.Error interface
+func isError(t reflect.Type) bool {
+	return t.Kind() == reflect.Interface && t.PkgPath() == "os" && t.Name() == "Error"
+}
+
+// printFuncFieldListUsingArgs generates the func field list with the given names, or with
+// argX names if nm is nil
+func (s *Spec) printFuncFieldListUsingArgs(w io.Writer, t reflect.Type, start int, nm *names) {
 	for i := start; i < t.NumIn(); i++ {
 		// names
-		fmt.Fprintf(w, "Arg%d ", i-start)
-		s.typesource(w, t.In(i))
+		fmt.Fprintf(w, "%s ", nm.arg(i-start))
+		s.parsource(w, t, i)
 		// ,
 		if i != t.NumIn()-1 {
 			fmt.Fprintf(w, ", ")
@@ -393,21 +1310,25 @@ func (s *Spec) printFuncFieldListUsingArgs(w io.Writer, t reflect.Type, start in
 	}
 }
 
-// printFuncResultList generates the func field list with type names
-func (s *Spec) printFuncResultList(w io.Writer, t reflect.Type) {
+// printFuncResultList generates the func result list with type names, and result names if
+// they can be declared
+func (s *Spec) printFuncResultList(w io.Writer, t reflect.Type, nm *names) {
 	outs := t.NumOut()
-	if outs > 1 {
+	if outs > 1 || nm.declared() != nil {
 		fmt.Fprintf(w, "(")
 	}
 	for i := 0; i < outs; i++ {
 		// names
+		if nm.declared() != nil {
+			fmt.Fprintf(w, "%s ", nm.outs[i])
+		}
 		s.typesource(w, t.Out(i))
 		// ,
 		if i != outs-1 {
 			fmt.Fprintf(w, ", ")
 		}
 	}
-	if outs > 1 {
+	if outs > 1 || nm.declared() != nil {
 		fmt.Fprintf(w, ") ")
 	} else {
 		fmt.Fprintf(w, " ")
@@ -415,7 +1336,7 @@ func (s *Spec) printFuncResultList(w io.Writer, t reflect.Type) {
 }
 
 // generateRemotizerCode returns the remotizer source code for a given set of Detected remotizables
-func generateRemotizerCode(pspecs []PreSpec) string {
+func generateRemotizerCode(pspecs []PreSpec, cfg *Config) string {
 	src := bytes.NewBuffer(make([]byte, 0))
 	fmt.Fprintf(src, remotizerHead)
 	genImports(src, pspecs)
@@ -426,11 +1347,19 @@ func generateRemotizerCode(pspecs []PreSpec) string {
 		if dcl,ok:=ps.(*decl); ok {
 			fmt.Fprintf(src, "\n\ttool.NewSpec(\"%v\",", ps.packname())
 			fmt.Fprintf(src, "%v,", dcl.isInterface)
-			fmt.Fprintf(src, "new(%v)),", ifacename(ps.name()))
+			fmt.Fprintf(src, "new(%v))", ifacename(ps.name()))
+			genInfos(src, dcl.infos)
+			genEmbedded(src, dcl.embedded)
+			genImpls(src, dcl.detected)
+			genConfig(src, cfg)
+			fmt.Fprintf(src, ",")
 		}
 		// ... or just a type name predefined elsewhere
 		if _,ok:=ps.(*predefined); ok { 
-			fmt.Fprintf(src, "\n\ttool.Value2Spec(\"%v\",new(%v)),", ps.packname(), ps.name())
+			fmt.Fprintf(src, "\n\ttool.Value2Spec(\"%v\",new(%v))", ps.packname(), ps.name())
+			genImpls(src, ps.(*predefined).detected)
+			genConfig(src, cfg)
+			fmt.Fprintf(src, ",")
 		}
 	}
 	fmt.Fprintf(src, "\n}\n\n")
@@ -438,11 +1367,74 @@ func generateRemotizerCode(pspecs []PreSpec) string {
 	return src.String()
 }
 
+// genInfos chains the source code info about methods to a remotizer Spec
+func genInfos(src io.Writer, infos map[string]*MethodInfo) {
+	methods := make([]string, 0)
+	for method, _ := range infos {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	for _, method := range methods {
+		info := infos[method]
+		fmt.Fprintf(src, ".\n\t\tWithInfo(%q, &tool.MethodInfo{Policy: %q, Pos: %q, "+
+			"NoCheck: %v, Params: %s, Results: %s, Doc: %q})", method, info.Policy, info.Pos,
+			info.NoCheck, stringsSource(info.Params), stringsSource(info.Results), info.Doc)
+	}
+}
+
+// genEmbedded chains the interfaces embedded from other packages to a remotizer Spec
+func genEmbedded(src io.Writer, embedded []string) {
+	for _, name := range embedded {
+		fmt.Fprintf(src, ".\n\t\tWithEmbedded(new(%s))", name)
+	}
+}
+
+// stringsSource returns the source code of a string slice literal
+func stringsSource(list []string) string {
+	if list == nil {
+		return "nil"
+	}
+	quoted := make([]string, len(list))
+	for i, s := range list {
+		quoted[i] = strconv.Quote(s)
+	}
+	return "[]string{" + strings.Join(quoted, ", ") + "}"
+}
+
+// genImpls chains the concrete types detected on the package source code to a remotizer Spec
+func genImpls(src io.Writer, d *detected) {
+	for _, name := range d.impls() {
+		fmt.Fprintf(src, ".\n\t\tWithImpl(%q", name)
+		for _, method := range d.methodNames(name) {
+			fmt.Fprintf(src, ", %q", method)
+		}
+		fmt.Fprintf(src, ")")
+	}
+}
+
+// genConfig chains the code generation choices to a remotizer Spec
+func genConfig(src io.Writer, cfg *Config) {
+	if cfg == nil {
+		return
+	}
+	if cfg.Output != "" {
+		fmt.Fprintf(src, ".\n\t\tWithFile(%q)", cfg.Output)
+	}
+	if cfg.Name != "" {
+		fmt.Fprintf(src, ".\n\t\tWithName(%q)", cfg.Name)
+	}
+	if cfg.Client != cfg.Server {
+		fmt.Fprintf(src, ".\n\t\tWithSides(%v, %v)", cfg.Client, cfg.Server)
+	}
+}
+
 // genImports adds imports to the remotizer source code from types
 func genImports(src io.Writer, pspecs []PreSpec) {
-	imports := []string{"tool"}
+	imports := []string{"tool", "fmt", "os"}
 	aliases:=make(map[string]string)
 	aliases["tool"] = "github.com/josvazg/remotize/tool"
+	aliases["fmt"] = "fmt"
+	aliases["os"] = "os"
 	for _, pspec:=range pspecs {
 		if decl,ok:=pspec.(*decl); ok {
 			for alias,name:=range decl.detected.aliases {
@@ -496,9 +1488,28 @@ func writeImports(w io.Writer, imports []string, aliases map[string]string, skip
 	fmt.Fprintf(w, ")\n\n")
 }
 
+// Config holds the choices about the code generated for the detected types or interfaces.
+type Config struct {
+	// Output is the file to write to, instead of remotized<Name>.go
+	Output string
+	// Name is the name of the interface generated for a type, instead of <Type>er
+	Name string
+	// Client and Server tell which side's code to generate, both if none is set
+	Client, Server bool
+}
+
 // BuildRemotizer generates a program to remotize the detected interfaces.
 func BuildRemotizer(pspecs []PreSpec) os.Error {
-	src := generateRemotizerCode(pspecs)
+	return BuildRemotizerWith(pspecs, nil)
+}
+
+// BuildRemotizerWith generates a program to remotize the detected interfaces as cfg says.
+func BuildRemotizerWith(pspecs []PreSpec, cfg *Config) os.Error {
+	if cfg != nil && len(pspecs) > 1 && (cfg.Output != "" || cfg.Name != "") {
+		return os.NewError("An output file or interface name can only be set when " +
+			"remotizing a single type or interface")
+	}
+	src := generateRemotizerCode(pspecs, cfg)
 	filename := "_remotizer"
 	if e := gofmtSave(filename, src); e != nil {
 		return e
@@ -538,7 +1549,11 @@ func goexec(tool string) string {
 		dict["compiler"] = "g"
 		dict["linker"] = "l"
 	}
-	return dict[os.Getenv("GOARCH")] + dict[tool]
+	arch := os.Getenv("GOARCH")
+	if arch == "" { // the remotizer runs here, so this architecture will do
+		arch = runtime.GOARCH
+	}
+	return dict[arch] + dict[tool]
 }
 
 // Go compiler
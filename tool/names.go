@@ -0,0 +1,277 @@
+// Copyright 2011 Jose Luis Vázquez González josvazg@gmail.com
+// Use of this source code is governed by a BSD-style
+
+package tool
+
+import (
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Identifiers used by the generated wrappers, that parameters can't be named after
+var reserved = map[string]bool{"args": true, "reply": true, "err": true, "id": true,
+	"l": true, "f": true, "b": true, "r": true, "s": true, "os": true, "remotize": true,
+	"new": true, "make": true}
+
+// names tells how the arguments and results of a method are called in the generated code:
+// as in the source code if known, or as Arg0, Arg1... otherwise. A nil names means unknown.
+type names struct {
+	ctx    string   // the Context parameter name, "" if the method takes none
+	ins    []string // parameter names, not counting the receiver nor the Context
+	outs   []string // result names
+	named  bool     // whether the generated methods can declare their results named as outs
+	nouts  int      // number of results
+	inouts []int    // pointer arguments returned within the reply, after the results
+}
+
+// names returns how the arguments and results of method m are called, where start tells
+// whether the first argument is the receiver
+func (s *Spec) names(m reflect.Method, start int) *names {
+	n := &names{nouts: m.Type.NumOut()}
+	count := m.Type.NumIn() - start
+	if takesContext(m.Type, start) {
+		n.ctx = "ctx"
+		start++
+	}
+	_, n.inouts = prepareInOuts(m.Type, start)
+	info, ok := s.infos[m.Name]
+	if !ok {
+		return n
+	}
+	packages := make(map[string]bool)
+	usedPackages(m.Type, packages)
+	if usable(info.Params, count, packages) {
+		n.ins = info.Params
+		if n.ctx != "" {
+			n.ctx, n.ins = info.Params[0], info.Params[1:]
+		}
+	}
+	if distinct(info.Results, n.nouts) { // the Reply fields are named after them
+		n.outs = info.Results
+		n.named = usable(info.Results, n.nouts, packages) &&
+			(n.ins != nil || !contains(n.outs, n.ctx))
+	}
+	return n
+}
+
+// usable tells whether the source names can name count parameters in the generated code:
+// they must be distinct, and must not shadow a package or a wrapper variable
+func usable(names []string, count int, packages map[string]bool) bool {
+	if !distinct(names, count) {
+		return false
+	}
+	for _, name := range names {
+		if reserved[name] || packages[name] || wrapperVar(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// distinct tells whether the source names can name count struct fields: all of them
+// must be named, unexported and unique
+func distinct(names []string, count int) bool {
+	if len(names) != count || count == 0 {
+		return false
+	}
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if name == "" || name == "_" || isExported(name) || seen[strings.ToLower(name)] {
+			return false
+		}
+		seen[strings.ToLower(name)] = true
+	}
+	return true
+}
+
+// contains tells whether name is one of names
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// wrapperVar tells whether name is like the numbered variables in the wrappers, as s0 or cli1
+func wrapperVar(name string) bool {
+	prefix := strings.TrimRight(name, "0123456789")
+	if prefix == name {
+		return false
+	}
+	switch prefix {
+	case "s", "c", "r", "o", "cli":
+		return true
+	}
+	return false
+}
+
+// usedPackages records the names of the packages the types within ft come from
+func usedPackages(t reflect.Type, packages map[string]bool) {
+	if t.Name() != "" {
+		if t.PkgPath() != "" {
+			packages[path2pack(t.PkgPath())] = true
+		}
+		return
+	}
+	switch t.Kind() {
+	case reflect.Array, reflect.Chan, reflect.Ptr, reflect.Slice:
+		usedPackages(t.Elem(), packages)
+	case reflect.Map:
+		usedPackages(t.Key(), packages)
+		usedPackages(t.Elem(), packages)
+	case reflect.Func:
+		for i := 0; i < t.NumIn(); i++ {
+			usedPackages(t.In(i), packages)
+		}
+		for i := 0; i < t.NumOut(); i++ {
+			usedPackages(t.Out(i), packages)
+		}
+	}
+}
+
+// arg returns the name of the i-th argument
+func (n *names) arg(i int) string {
+	if n.params() == nil {
+		return fmt.Sprintf("Arg%d", i)
+	}
+	return n.ins[i]
+}
+
+// context returns the name of the Context parameter, or "" if there is none
+func (n *names) context() string {
+	if n == nil {
+		return ""
+	}
+	return n.ctx
+}
+
+// param returns the name of the i-th parameter, counting the Context, if any
+func (n *names) param(i int) string {
+	if n.context() != "" {
+		if i == 0 {
+			return n.ctx
+		}
+		i--
+	}
+	return n.arg(i)
+}
+
+// args returns the Args struct field names and JSON tags
+func (n *names) args(count int) ([]string, []string) {
+	fields := make([]string, count)
+	tags := make([]string, count)
+	for i := 0; i < count; i++ {
+		fields[i], tags[i] = n.field(n.params(), i, i)
+	}
+	return fields, tags
+}
+
+// replies returns the Reply struct field names and JSON tags, for the results followed
+// by the pointer arguments returned
+func (n *names) replies(count int) ([]string, []string) {
+	fields := make([]string, count)
+	tags := make([]string, count)
+	for i := 0; i < count; i++ {
+		fields[i], tags[i] = n.replyField(i)
+	}
+	return fields, tags
+}
+
+// argField returns the Args struct field name of the i-th argument
+func (n *names) argField(i int) string {
+	field, _ := n.field(n.params(), i, i)
+	return field
+}
+
+// replyField returns the Reply struct field name and JSON tag of the i-th reply value
+func (n *names) replyField(i int) (string, string) {
+	if n == nil || i < n.nouts {
+		return n.field(n.results(), i, i)
+	}
+	return n.field(n.params(), n.inouts[i-n.nouts], i)
+}
+
+// params returns the parameter names, if known
+func (n *names) params() []string {
+	if n == nil {
+		return nil
+	}
+	return n.ins
+}
+
+// results returns the result names, if known
+func (n *names) results() []string {
+	if n == nil {
+		return nil
+	}
+	return n.outs
+}
+
+// declared returns the result names the generated methods declare, if any
+func (n *names) declared() []string {
+	if n == nil || !n.named {
+		return nil
+	}
+	return n.outs
+}
+
+// field returns the struct field name and JSON tag for the i-th of the source names,
+// or those of the given position if they are not known
+func (n *names) field(source []string, i, pos int) (string, string) {
+	if source == nil {
+		return fmt.Sprintf("Arg%d", pos), fmt.Sprintf("arg%d", pos)
+	}
+	return strings.ToUpper(source[i][:1]) + source[i][1:], source[i]
+}
+
+// paramNames returns the names of the parameters in a field list, with "" for unnamed ones,
+// or nil if there are none
+func paramNames(fl *ast.FieldList) []string {
+	if fl == nil || len(fl.List) == 0 {
+		return nil
+	}
+	names := make([]string, 0)
+	for _, field := range fl.List {
+		if len(field.Names) == 0 {
+			names = append(names, "")
+		}
+		for _, name := range field.Names {
+			names = append(names, name.Name)
+		}
+	}
+	return names
+}
+
+// docText returns the text of a doc comment, without any '(remotize ...)' annotation
+func docText(comment *ast.CommentGroup) string {
+	if comment == nil {
+		return ""
+	}
+	lines := make([]string, 0)
+	for _, line := range strings.Split(doc.CommentText(comment), "\n") {
+		if !strings.Contains(strings.ToLower(line), "(remotize") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// genDoc generates a doc comment with the given text, indented by indent
+func genDoc(w io.Writer, text, indent string) {
+	if text == "" {
+		return
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if line == "" {
+			fmt.Fprintf(w, "%s//\n", indent)
+		} else {
+			fmt.Fprintf(w, "%s// %s\n", indent, line)
+		}
+	}
+}
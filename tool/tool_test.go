@@ -2,8 +2,16 @@ package tool
 
 import (
 	//"fmt"
+	"go/ast"
+	"github.com/josvazg/remotize"
 	"go/build"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"unsafe"
 )
 
 type SomeInterface interface {
@@ -14,6 +22,10 @@ type SomeStruct struct {
 
 }
 
+type SomeHandler interface {
+	Handle(SomeStruct) bool
+}
+
 type ToolTester interface {
 	SomeOp(*int, int)
 	Integers(int, int8, int16, int32, int16, int64) (int, int8,
@@ -49,10 +61,28 @@ type ToolTester interface {
 	[]uint64)
 	Sfloats([]float32, []float64) ([]float32, []float64)
 	Scomplexs([]complex64, []complex128) ([]complex64, []complex128)
+	Vintegers(string, ...int) int
+	Vstrings(...string) []string
+	Vtypes(SomeStruct, ...SomeStruct) []SomeStruct
+	Vinterfaces(...SomeInterface) (SomeInterface, bool)
+	Vpointers(*int, ...*SomeStruct)
+	Watch(string) <-chan SomeStruct
+	Feed(<-chan int) bool
+	Fill(chan<- string, int) int
+	Pipe(chan []byte) chan []byte
+	Sink() (chan<- []byte, bool)
+	Subscribe(func(SomeStruct) bool) bool
+	Progress(string, func(int, ...string))
+	Handle(int, SomeHandler)
+	Open(string) (SomeHandler, bool)
+	Await(remotize.Context, string) (int, os.Error)
 }
 
 func TestTool(t *testing.T) {
-	if e := Remotize(Value2Spec("github.com/josvazg/remotize/tool",new(ToolTester))); e != nil {
+	spec := Value2Spec("github.com/josvazg/remotize/tool", new(ToolTester)).
+		WithInfo("Others", &MethodInfo{Policy: "idempotent timeout=2s retries=3"}).
+		WithImpl("SomeStruct")
+	if e := Remotize(spec); e != nil {
 		t.Fatal(e)
 	}
 	dir, e := build.ScanDir(".", false)
@@ -76,3 +106,336 @@ func TestTool(t *testing.T) {
 		t.Fatal(e)
 	}
 }
+
+func TestAnnotation(t *testing.T) {
+	tests := []struct {
+		text     string
+		settings string
+		ok       bool
+	}{
+		{"// (remotize)", "", true},
+		{"// Get something (Remotize: Idempotent timeout=2s)", "idempotent timeout=2s", true},
+		{"// (remotized)", "", false},
+		{"// just a comment", "", false},
+	}
+	for _, test := range tests {
+		doc := &ast.CommentGroup{[]*ast.Comment{&ast.Comment{Text: test.text}}}
+		settings, ok := annotation(doc)
+		if settings != test.settings || ok != test.ok {
+			t.Fatalf("Expected '%s',%v from '%s' but got '%s',%v!", test.settings, test.ok,
+				test.text, settings, ok)
+		}
+	}
+	spec := Value2Spec("github.com/josvazg/remotize/tool", new(SomeHandler)).
+		WithInfo("Handle", &MethodInfo{Policy: "forever"})
+	if e := Remotize(spec); e == nil {
+		t.Fatal("Expected an error remotizing a bad policy!")
+	}
+}
+
+func TestGobTypes(t *testing.T) {
+	spec := Value2Spec("github.com/josvazg/remotize/tool", new(ToolTester)).
+		WithImpl("SomeStruct").WithImpl("*someHandler", "Handle")
+	gts := spec.gobTypes()
+	if len(gts) != 2 || gts[0] != "*new(SomeStruct)" || gts[1] != "new(someHandler)" {
+		t.Fatalf("Unexpected gob types %v!", gts)
+	}
+	spec = Value2Spec("github.com/josvazg/remotize/tool", new(SomeHandler)).
+		WithImpl("SomeStruct")
+	if gts := spec.gobTypes(); len(gts) != 0 {
+		t.Fatalf("Expected no gob types but got %v!", gts)
+	}
+}
+
+type hidden struct {
+	Shown  int
+	hidden int
+}
+
+type Node struct {
+	Next *Node
+}
+
+type BadTester interface {
+	Nested([]chan int)
+	Unsafe(unsafe.Pointer)
+	Hidden(hidden)
+	Recursive(*Node)
+	Handlers([]SomeHandler)
+	Fine(int, <-chan SomeStruct, func(int) bool) (string, os.Error)
+}
+
+func TestCheck(t *testing.T) {
+	spec := Value2Spec("github.com/josvazg/remotize/tool", new(BadTester)).
+		WithInfo("Hidden", &MethodInfo{Pos: "bad.go:7"})
+	problems := spec.check()
+	if len(problems) != 5 {
+		t.Fatalf("Expected 5 problems but got %v!", problems)
+	}
+	for _, problem := range problems {
+		if strings.Contains(problem, "Fine") {
+			t.Fatalf("Unexpected problem %s!", problem)
+		}
+		if strings.Contains(problem, "Hidden") && !strings.HasPrefix(problem, "bad.go:7: ") {
+			t.Fatalf("Expected problem %s to be located!", problem)
+		}
+	}
+	if e := Remotize(spec); e == nil {
+		t.Fatal("Expected an error remotizing a bad type!")
+	}
+	spec.WithImpl("*someHandler", "Handle").WithInfo("Nested", &MethodInfo{NoCheck: true})
+	if problems := spec.check(); len(problems) != 3 {
+		t.Fatalf("Expected 3 problems but got %v!", problems)
+	}
+	if problems := spec.WithInfo("", &MethodInfo{NoCheck: true}).check(); len(problems) != 0 {
+		t.Fatalf("Expected no problems but got %v!", problems)
+	}
+	if policy, nocheck := splitNoCheck("idempotent nocheck timeout=2s"); policy !=
+		"idempotent timeout=2s" || !nocheck {
+		t.Fatalf("Unexpected policy '%s' and nocheck %v!", policy, nocheck)
+	}
+}
+
+type ClashTester interface {
+	Send() int
+	Len() int
+	Batch()
+	RemotizeRelease() os.Error
+	Get(string) string
+	GetAsync(string) string
+	Watch(chan int)
+	WatchAsync(chan int)
+}
+
+func TestCheckNames(t *testing.T) {
+	spec := Value2Spec("github.com/josvazg/remotize/tool", new(ClashTester)).
+		WithInfo("", &MethodInfo{NoCheck: true})
+	problems := spec.check()
+	if len(problems) != 5 {
+		t.Fatalf("Expected 5 clashes but got %v!", problems)
+	}
+	for _, problem := range problems {
+		if strings.Contains(problem, "WatchAsync") {
+			t.Fatalf("Unexpected clash %s, streamed methods get no Async variant!", problem)
+		}
+	}
+	if problems := spec.WithSides(false, true).check(); len(problems) != 1 {
+		t.Fatalf("Expected just the Remotize clash without client but got %v!", problems)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	pack := "github.com/josvazg/remotize/tool"
+	spec := Value2Spec(pack, new(SomeHandler)).WithName("Handler")
+	if e := Remotize(spec); e == nil {
+		t.Fatal("Expected an error renaming an interface!")
+	}
+	client := Value2Spec(pack, new(SomeHandler)).WithSides(true, false).buildBody()
+	if strings.Contains(client, "SomeHandlerService struct") ||
+		!strings.Contains(client, "RemoteSomeHandler struct") {
+		t.Fatalf("Expected client side code only but got:\n%s", client)
+	}
+	server := Value2Spec(pack, new(SomeHandler)).WithSides(false, true).buildBody()
+	if !strings.Contains(server, "SomeHandlerService struct") ||
+		strings.Contains(server, "RemoteSomeHandler struct") {
+		t.Fatalf("Expected server side code only but got:\n%s", server)
+	}
+	if Value2Spec(pack, new(SomeHandler)).buildBody() !=
+		Value2Spec(pack, new(SomeHandler)).buildBody() {
+		t.Fatal("Expected the same code generated twice!")
+	}
+	pspecs := []PreSpec{&predefined{nil, "dep.FileService"},
+		&decl{nil, "URLStore", false, nil, nil, nil, nil}, &decl{nil, "*URLStore", false, nil, nil, nil, nil}}
+	selected, e := Select(pspecs, "FileService", "URLStore")
+	if e != nil || len(selected) != 3 {
+		t.Fatalf("Unexpected selection %v (%v)!", selected, e)
+	}
+	if _, e := Select(pspecs, "Missing"); e == nil {
+		t.Fatal("Expected an error selecting a missing type!")
+	}
+}
+
+func TestDetectPackages(t *testing.T) {
+	if _, e := Detect("detect.go", "../remotize.go"); e == nil {
+		t.Fatal("Expected an error detecting files from two packages!")
+	}
+}
+
+const embedding = `package fake
+
+import "io"
+
+type Base interface {
+	Close() os.Error
+}
+
+// (remotize)
+type Store interface {
+	Base
+	io.Reader
+	Get(key string) string
+}
+`
+
+const clashing = `package fake
+
+type Base interface {
+	Close() os.Error
+}
+
+// (remotize)
+type Clash interface {
+	Base
+	Close() os.Error
+}
+`
+
+func detectSource(t *testing.T, src string) ([]PreSpec, os.Error) {
+	file := filepath.Join(os.TempDir(), "remotize_embedding.go")
+	if e := ioutil.WriteFile(file, []byte(src), 0644); e != nil {
+		t.Fatal(e)
+	}
+	defer os.Remove(file)
+	return Detect(file)
+}
+
+func TestEmbedded(t *testing.T) {
+	pspecs, e := detectSource(t, embedding)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(pspecs) != 1 {
+		t.Fatalf("Expected just Store but got %v!", pspecs)
+	}
+	dcl := pspecs[0].(*decl)
+	src := dcl.Src.String()
+	for _, expected := range []string{"Close() os.Error", "Get(key string) string"} {
+		if !strings.Contains(src, expected) {
+			t.Fatalf("Expected %s within:\n%s", expected, src)
+		}
+	}
+	if strings.Contains(src, "\tBase") || strings.Contains(src, "io.Reader") {
+		t.Fatalf("Expected Base flattened and io.Reader left to reflection within:\n%s", src)
+	}
+	if len(dcl.embedded) != 1 || dcl.embedded[0] != "io.Reader" {
+		t.Fatalf("Expected io.Reader embedded but got %v!", dcl.embedded)
+	}
+	if code := generateRemotizerCode(pspecs, nil); !strings.Contains(code,
+		"WithEmbedded(new(io.Reader))") {
+		t.Fatalf("Expected io.Reader handed to the remotizer within:\n%s", code)
+	}
+	if _, e := detectSource(t, clashing); e == nil {
+		t.Fatal("Expected an error detecting clashing methods!")
+	}
+	spec := Value2Spec("github.com/josvazg/remotize/tool", new(SomeHandler)).
+		WithEmbedded(new(io.Reader))
+	if e := spec.checkEmbedded(); e != nil {
+		t.Fatal(e)
+	}
+	if methods := spec.methods(); len(methods) != 2 || methods[0].Name != "Handle" ||
+		methods[1].Name != "Read" {
+		t.Fatalf("Expected Handle and Read but got %v!", methods)
+	}
+	spec = Value2Spec("github.com/josvazg/remotize/tool", new(ReadClasher)).
+		WithInfo("Read", &MethodInfo{Pos: "clash.go:7"}).WithEmbedded(new(io.Reader))
+	expected := "clash.go:7: Can't remotize ReadClasher: method Read of ReadClasher " +
+		"clashes with the one of io.Reader"
+	if e := spec.checkEmbedded(); e == nil || e.String() != expected {
+		t.Fatalf("Expected '%s' but got %v!", expected, e)
+	}
+}
+
+// ReadClasher declares the Read of the io.Reader it would embed
+type ReadClasher interface {
+	Read(b []byte) (int, os.Error)
+}
+
+const documented = `package fake
+
+// (remotize)
+type Lister interface {
+	// List returns the names under prefix,
+	// up to max of them.
+	List(prefix string, max int) (names []string, err os.Error)
+	Count(string) int
+}
+`
+
+// Lister is the documented Lister, as the remotizer sees it
+type Lister interface {
+	List(prefix string, max int) ([]string, os.Error)
+	Count(string) int
+}
+
+func TestNames(t *testing.T) {
+	pspecs, e := detectSource(t, documented)
+	if e != nil {
+		t.Fatal(e)
+	}
+	infos := pspecs[0].(*decl).infos
+	if list := infos["List"]; list == nil || len(list.Params) != 2 || list.Params[1] != "max" ||
+		len(list.Results) != 2 || list.Doc != "List returns the names under prefix,\nup to max of them." {
+		t.Fatalf("Unexpected List info %v!", list)
+	}
+	spec := Value2Spec("github.com/josvazg/remotize/tool", new(ToolTester)).
+		WithInfo("Feed", &MethodInfo{Params: []string{"values"}, Results: []string{"ok"},
+		Doc: "Feed takes values."}).
+		WithInfo("SomeOp", &MethodInfo{Params: []string{"total", "n"}}).
+		WithInfo("Others", &MethodInfo{Params: []string{"args", "s"}})
+	body := spec.buildBody()
+	for _, expected := range []string{"// Feed takes values.\nfunc (l *RemoteToolTester) Feed(values ",
+		"Values uint64 `json:\"values\"`", "Ok bool `json:\"ok\"`", "*total = *reply.Total",
+		"Others(Arg0 bool, Arg1 string)"} {
+		if !strings.Contains(body, expected) {
+			t.Fatalf("Expected %s within:\n%s", expected, body)
+		}
+	}
+	if expected := "Feed(values <-chan int) (ok bool) {"; !strings.Contains(body, expected) {
+		t.Fatalf("Expected %s within:\n%s", expected, body)
+	}
+	def := spec.buildInterfaceDef()
+	if !strings.Contains(def, "    // Feed takes values.\n    Feed(values <-chan int) (ok bool)") {
+		t.Fatalf("Expected Feed named and documented within:\n%s", def)
+	}
+	body = Value2Spec("github.com/josvazg/remotize/tool", new(Lister)).
+		WithInfo("List", infos["List"]).buildBody()
+	for _, expected := range []string{"Names []string `json:\"names\"`",
+		"Err os.Error `json:\"err\"`", "List(prefix string, max int) ([]string, os.Error) {"} {
+		if !strings.Contains(body, expected) {
+			t.Fatalf("Expected %s within:\n%s", expected, body)
+		}
+	}
+}
+
+func TestContext(t *testing.T) {
+	spec := Value2Spec("github.com/josvazg/remotize/tool", new(ToolTester)).
+		WithInfo("Await", &MethodInfo{Params: []string{"c", "event"}})
+	if problems := spec.check(); len(problems) != 0 {
+		t.Fatalf("Expected no problems but got %v!", problems)
+	}
+	body := spec.buildBody()
+	for _, expected := range []string{"Await(c remotize.Context, event string) (int, os.Error)",
+		"args.RemotizeDeadline = remotize.Remaining(c)", "args.RemotizeCall = remotize.NewCallId()",
+		"l.opts.CallContext(c, args.RemotizeCall, l.cli",
+		"remotize.ServerContext(args.RemotizeDeadline, args.RemotizeCall)",
+		"RemotizeCancel(args, reply *remotize.CancelData)", "r.srv.Await(ctx, args.Event)"} {
+		if !strings.Contains(body, expected) {
+			t.Fatalf("Expected %s within:\n%s", expected, body)
+		}
+	}
+	for _, unexpected := range []string{"\tC remotize.Context", "AwaitAsync", "Batch) Await"} {
+		if strings.Contains(body, unexpected) {
+			t.Fatalf("Unexpected %s within:\n%s", unexpected, body)
+		}
+	}
+}
+
+func TestNilChannels(t *testing.T) {
+	body := Value2Spec("github.com/josvazg/remotize/tool", new(ToolTester)).buildBody()
+	for _, expected := range []string{"if args.Arg0 != 0 {\n\t\ts0, err := remotize.FindStream",
+		"if Arg0 != nil {\n\t\ts0, err := remotize.OpenStream"} {
+		if !strings.Contains(body, expected) {
+			t.Fatalf("Expected %s within:\n%s", expected, body)
+		}
+	}
+}
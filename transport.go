@@ -0,0 +1,161 @@
+// Copyright 2011 Jose Luis Vázquez González josvazg@gmail.com
+// Use of this source code is governed by a BSD-style
+
+package remotize
+
+import (
+	"bufio"
+	"gob"
+	"io"
+	"net"
+	"os"
+	"rpc"
+	"rpc/jsonrpc"
+)
+
+// Transport is the wire format remotized calls travel with.
+type Transport int
+
+const (
+	// Gob is the net/rpc default gob encoding, only Go speaks it
+	Gob Transport = iota
+	// JSON is the rpc/jsonrpc JSON-RPC 1.0 codec
+	JSON
+	// JSON2 is the standalone JSON-RPC 2.0 framing, for non Go clients
+	JSON2
+)
+
+// clientCodec returns the client codec of transport t on conn.
+func clientCodec(t Transport, conn io.ReadWriteCloser) rpc.ClientCodec {
+	switch t {
+	case JSON:
+		return jsonrpc.NewClientCodec(conn)
+	case JSON2:
+		return NewJSON2ClientCodec(conn)
+	}
+	buf := bufio.NewWriter(conn)
+	return &gobClientCodec{conn, gob.NewDecoder(conn), gob.NewEncoder(buf), buf}
+}
+
+// serverCodec returns the server codec of transport t on conn.
+func serverCodec(t Transport, conn io.ReadWriteCloser) rpc.ServerCodec {
+	switch t {
+	case JSON:
+		return jsonrpc.NewServerCodec(conn)
+	case JSON2:
+		return NewJSON2ServerCodec(conn)
+	}
+	buf := bufio.NewWriter(conn)
+	return &gobServerCodec{conn, gob.NewDecoder(conn), gob.NewEncoder(buf), buf}
+}
+
+// NewClient returns an rpc client calling through conn with transport t.
+func NewClient(t Transport, conn io.ReadWriteCloser) *rpc.Client {
+	return rpc.NewClientWithCodec(clientCodec(t, conn))
+}
+
+// Dial connects to the rpc server at address on network with transport t.
+func Dial(t Transport, network, address string) (*rpc.Client, os.Error) {
+	conn, e := net.Dial(network, address)
+	if e != nil {
+		return nil, e
+	}
+	return NewClient(t, conn), nil
+}
+
+// ServeConn serves srv's services on conn with transport t. It blocks until
+// the client hangs up.
+func ServeConn(t Transport, srv *rpc.Server, conn io.ReadWriteCloser) {
+	srv.ServeCodec(serverCodec(t, conn))
+}
+
+// Serve accepts connections on l, serving srv's services on each of them
+// with transport t.
+func Serve(t Transport, srv *rpc.Server, l net.Listener) os.Error {
+	for {
+		conn, e := l.Accept()
+		if e != nil {
+			return e
+		}
+		go ServeConn(t, srv, conn)
+	}
+	panic("unreachable")
+}
+
+// NewLoopback returns an rpc client calling the given services, served by a
+// rpc.Server of their own within this same process. Calls go gob encoded
+// through an in-memory pipe, just as they would through the network, but
+// there are no sockets or ports involved, so tests using it are hermetic.
+//
+// Services are usually the service wrappers returned by NewService.
+func NewLoopback(services ...interface{}) (*rpc.Client, os.Error) {
+	srv := rpc.NewServer()
+	for _, service := range services {
+		if e := srv.Register(service); e != nil {
+			return nil, e
+		}
+	}
+	c, s := net.Pipe()
+	go srv.ServeConn(s)
+	return rpc.NewClient(c), nil
+}
+
+// gobClientCodec is the net/rpc gob client codec, which is not exported.
+type gobClientCodec struct {
+	rwc    io.ReadWriteCloser
+	dec    *gob.Decoder
+	enc    *gob.Encoder
+	encBuf *bufio.Writer
+}
+
+func (c *gobClientCodec) WriteRequest(r *rpc.Request, body interface{}) os.Error {
+	if e := c.enc.Encode(r); e != nil {
+		return e
+	}
+	if e := c.enc.Encode(body); e != nil {
+		return e
+	}
+	return c.encBuf.Flush()
+}
+
+func (c *gobClientCodec) ReadResponseHeader(r *rpc.Response) os.Error {
+	return c.dec.Decode(r)
+}
+
+func (c *gobClientCodec) ReadResponseBody(body interface{}) os.Error {
+	return c.dec.Decode(body)
+}
+
+func (c *gobClientCodec) Close() os.Error {
+	return c.rwc.Close()
+}
+
+// gobServerCodec is the net/rpc gob server codec, which is not exported.
+type gobServerCodec struct {
+	rwc    io.ReadWriteCloser
+	dec    *gob.Decoder
+	enc    *gob.Encoder
+	encBuf *bufio.Writer
+}
+
+func (c *gobServerCodec) ReadRequestHeader(r *rpc.Request) os.Error {
+	return c.dec.Decode(r)
+}
+
+func (c *gobServerCodec) ReadRequestBody(body interface{}) os.Error {
+	return c.dec.Decode(body)
+}
+
+func (c *gobServerCodec) WriteResponse(r *rpc.Response, body interface{}) os.Error {
+	if e := c.enc.Encode(r); e != nil {
+		return e
+	}
+	if e := c.enc.Encode(body); e != nil {
+		return e
+	}
+	return c.encBuf.Flush()
+}
+
+func (c *gobServerCodec) Close() os.Error {
+	return c.rwc.Close()
+}